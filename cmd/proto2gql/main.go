@@ -56,6 +56,12 @@ var (
 	filterN string
 
 	noPrefix bool
+
+	oneofUnion bool
+
+	allInputs bool
+
+	queryPrefixes string
 )
 
 func main() {
@@ -71,6 +77,9 @@ func main() {
 	flag.StringVar(&filter, "filter", "", "Regexp to filter out matched custom types")
 	flag.StringVar(&filterN, "filterN", "", "Regexp to filter out not matched custom types")
 	flag.BoolVar(&noPrefix, "no_prefix", false, "Disables package prefix for type names")
+	flag.StringVar(&queryPrefixes, "query_prefixes", "Get,List", "Comma separated name prefixes of RPCs that are queries instead of mutations")
+	flag.BoolVar(&allInputs, "all_inputs", false, "Generates input types for all messages instead of those used by requests of RPCs")
+	flag.BoolVar(&oneofUnion, "oneof_union", false, "Converts message fields of oneofs into union types instead of nullable fields")
 
 	flag.Parse()
 
@@ -127,6 +136,9 @@ func main() {
 		withPackageAliases(packageAliases),
 		withNoPrefix(noPrefix),
 		withFilter(filter, filterN),
+		withOneofUnion(oneofUnion),
+		withAllInputs(allInputs),
+		withQueryPrefixes(queryPrefixes),
 	)
 
 	for _, filename := range flag.Args() {
@@ -162,6 +174,36 @@ func withNoPrefix(noPrefix bool) func(transformer *proto2gql.Transformer) {
 	}
 }
 
+func withOneofUnion(union bool) func(transformer *proto2gql.Transformer) {
+	return func(t *proto2gql.Transformer) {
+		if union == true {
+			t.SetOneofStrategy(proto2gql.UnionOneofs)
+		}
+	}
+}
+
+func withAllInputs(all bool) func(transformer *proto2gql.Transformer) {
+	return func(t *proto2gql.Transformer) {
+		if all == true {
+			t.SetInputStrategy(proto2gql.AllInputs)
+		}
+	}
+}
+
+func withQueryPrefixes(prefixes string) func(transformer *proto2gql.Transformer) {
+	return func(t *proto2gql.Transformer) {
+		list := make([]string, 0, 2)
+
+		for _, prefix := range strings.Split(prefixes, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				list = append(list, prefix)
+			}
+		}
+
+		t.SetQueryPrefixes(list...)
+	}
+}
+
 func withFilter(positive, negative string) func(transformer *proto2gql.Transformer) {
 	return func(t *proto2gql.Transformer) {
 		if positive == "" && negative == "" {
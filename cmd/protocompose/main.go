@@ -1,9 +1,7 @@
 package main
 
 import (
-	"bytes"
 	"flag"
-	"io"
 	"log"
 	"os"
 
@@ -27,7 +25,7 @@ func main() {
 	out, err := os.Create(*oOutput)
 	check(err)
 	defer out.Close()
-	io.WriteString(out, formatted(toProcess))
+	check(protofmt.Node(out, toProcess, protofmt.DefaultFormatterOptions()))
 }
 
 func FieldOfMessage(m *proto.Message, fieldName string) proto.Visitee {
@@ -62,10 +60,3 @@ func check(err error) {
 		log.Fatal(err)
 	}
 }
-
-func formatted(v proto.Visitee) string {
-	b := new(bytes.Buffer)
-	f := protofmt.NewFormatter(b, "  ") // 2 spaces
-	v.Accept(f)
-	return b.String()
-}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+)
+
+// names of the configuration files, looked up in the directory of a file and its parents.
+const (
+	yamlConfigName = ".protofmt.yaml"
+	jsonConfigName = ".protofmt.json"
+)
+
+// config holds the settings of a configuration file. Settings that are absent are nil.
+// The names of the settings are those of the flags, if there is one.
+type config struct {
+	IndentWidth       *int     `json:"indent-width"`
+	UseTabs           *bool    `json:"use-tabs"`
+	AlignColumns      *bool    `json:"align-columns"`
+	BlankLines        *int     `json:"blank-lines"`
+	MaxWidth          *int     `json:"max-width"`
+	CommentStyle      *string  `json:"comment-style"`
+	CommentWidth      *int     `json:"comment-width"`
+	NormalizeComments *bool    `json:"normalize-comments"`
+	NormalizeLiterals *bool    `json:"normalize-literals"`
+	DecimalNumbers    *bool    `json:"decimal-numbers"`
+	SortImports       *bool    `json:"sort-imports"`
+	LocalImports      []string `json:"local-imports"`
+	Canonical         *bool    `json:"canonical"`
+	SortFields        *bool    `json:"sort-fields"`
+	KeepBlankLines    *bool    `json:"keep-blank-lines"`
+}
+
+var commentStyles = map[string]protofmt.CommentStyle{
+	"preserve": protofmt.CommentStylePreserve,
+	"line":     protofmt.CommentStyleLine,
+	"block":    protofmt.CommentStyleBlock,
+}
+
+// applyTo changes the options for each setting that is present.
+func (c config) applyTo(opts *protofmt.FormatterOptions) error {
+	if c.IndentWidth != nil {
+		opts.IndentWidth = *c.IndentWidth
+	}
+	if c.UseTabs != nil {
+		opts.UseTabs = *c.UseTabs
+	}
+	if c.AlignColumns != nil {
+		opts.AlignColumns = *c.AlignColumns
+	}
+	if c.BlankLines != nil {
+		opts.BlankLinesBetweenDeclarations = *c.BlankLines
+	}
+	if c.MaxWidth != nil {
+		opts.MaxLineWidth = *c.MaxWidth
+	}
+	if c.CommentStyle != nil {
+		style, ok := commentStyles[*c.CommentStyle]
+		if !ok {
+			return fmt.Errorf("unknown comment-style %q, must be one of preserve, line or block", *c.CommentStyle)
+		}
+		opts.CommentStyle = style
+	}
+	if c.CommentWidth != nil {
+		opts.CommentWidth = *c.CommentWidth
+	}
+	if c.NormalizeComments != nil {
+		opts.NormalizeComments = *c.NormalizeComments
+	}
+	if c.NormalizeLiterals != nil {
+		opts.NormalizeLiterals = *c.NormalizeLiterals
+	}
+	if c.DecimalNumbers != nil {
+		opts.DecimalNumbers = *c.DecimalNumbers
+	}
+	if c.SortImports != nil {
+		opts.SortImports = *c.SortImports
+	}
+	if c.LocalImports != nil {
+		opts.LocalImportPrefixes = c.LocalImports
+	}
+	if c.Canonical != nil {
+		opts.CanonicalOrder = *c.Canonical
+	}
+	if c.SortFields != nil {
+		opts.SortMessageElements = *c.SortFields
+	}
+	if c.KeepBlankLines != nil {
+		opts.PreserveBlankLines = *c.KeepBlankLines
+	}
+	return nil
+}
+
+// configOf returns the settings of options with all settings present.
+func configOf(opts protofmt.FormatterOptions) config {
+	style := "preserve"
+	for name, each := range commentStyles {
+		if each == opts.CommentStyle {
+			style = name
+		}
+	}
+	local := append([]string{}, opts.LocalImportPrefixes...)
+	return config{
+		IndentWidth:       &opts.IndentWidth,
+		UseTabs:           &opts.UseTabs,
+		AlignColumns:      &opts.AlignColumns,
+		BlankLines:        &opts.BlankLinesBetweenDeclarations,
+		MaxWidth:          &opts.MaxLineWidth,
+		CommentStyle:      &style,
+		CommentWidth:      &opts.CommentWidth,
+		NormalizeComments: &opts.NormalizeComments,
+		NormalizeLiterals: &opts.NormalizeLiterals,
+		DecimalNumbers:    &opts.DecimalNumbers,
+		SortImports:       &opts.SortImports,
+		LocalImports:      local,
+		Canonical:         &opts.CanonicalOrder,
+		SortFields:        &opts.SortMessageElements,
+		KeepBlankLines:    &opts.PreserveBlankLines,
+	}
+}
+
+// configFinder finds and reads configuration files. Files are read once per directory.
+type configFinder struct {
+	mutex sync.Mutex
+	// configs holds the settings of the configuration file in a directory, nil if there is none
+	configs map[string]*config
+	// paths holds the path of the configuration file in a directory
+	paths map[string]string
+}
+
+var configs = &configFinder{configs: map[string]*config{}, paths: map[string]string{}}
+
+// optionsFor returns the options to format a file (or the files in a directory): the defaults changed
+// by the configuration files, from the top-most directory down to the closest one, and then by the flags.
+// It also returns the paths of the configuration files that are used.
+func (c *configFinder) optionsFor(path string) (protofmt.FormatterOptions, []string, error) {
+	opts := protofmt.DefaultFormatterOptions()
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return opts, nil, err
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		abs = filepath.Dir(abs)
+	}
+	dirs := []string{}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	used := []string{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		cfg, file, err := c.configIn(dirs[i])
+		if err != nil {
+			return opts, used, err
+		}
+		if cfg == nil {
+			continue
+		}
+		if err := cfg.applyTo(&opts); err != nil {
+			return opts, used, fmt.Errorf("%s: %v", file, err)
+		}
+		used = append(used, file)
+	}
+	return opts, used, flagConfig().applyTo(&opts)
+}
+
+// configIn returns the settings of the configuration file in a directory, if any.
+func (c *configFinder) configIn(dir string) (*config, string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cfg, ok := c.configs[dir]; ok {
+		return cfg, c.paths[dir], nil
+	}
+	cfg, file, err := readConfigIn(dir)
+	if err != nil {
+		return nil, file, err
+	}
+	c.configs[dir], c.paths[dir] = cfg, file
+	return cfg, file, nil
+}
+
+// readConfigIn reads the configuration file in a directory; a directory can have only one.
+func readConfigIn(dir string) (*config, string, error) {
+	found := ""
+	for _, each := range []string{yamlConfigName, jsonConfigName} {
+		file := filepath.Join(dir, each)
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		if len(found) > 0 {
+			return nil, file, fmt.Errorf("%s: cannot be used together with %s", file, found)
+		}
+		found = file
+	}
+	if len(found) == 0 {
+		return nil, "", nil
+	}
+	data, err := ioutil.ReadFile(found)
+	if err != nil {
+		return nil, found, err
+	}
+	if strings.HasSuffix(found, ".yaml") {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, found, fmt.Errorf("%s: %v", found, err)
+		}
+	}
+	cfg := new(config)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, found, fmt.Errorf("%s: %v", found, err)
+	}
+	return cfg, found, nil
+}
+
+// yamlToJSON converts a YAML mapping of settings to JSON.
+// Only the subset of YAML needed for settings is supported: keys with a scalar value
+// and keys with a list of scalars, written as [a, b] or as lines starting with a dash.
+func yamlToJSON(data []byte) ([]byte, error) {
+	settings := map[string]interface{}{}
+	var list *[]interface{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(withoutYAMLComment(line), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if list == nil {
+				return nil, fmt.Errorf("line %d: list item without key", i+1)
+			}
+			*list = append(*list, yamlScalar(strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))))
+			continue
+		}
+		if trimmed != line {
+			return nil, fmt.Errorf("line %d: nested values are not supported", i+1)
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		key, value := strings.TrimSpace(line[:colon]), strings.TrimSpace(line[colon+1:])
+		list = nil
+		switch {
+		case len(value) == 0:
+			items := []interface{}{}
+			settings[key] = &items
+			list = &items
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := []interface{}{}
+			for _, each := range strings.Split(value[1:len(value)-1], ",") {
+				if each = strings.TrimSpace(each); len(each) > 0 {
+					items = append(items, yamlScalar(each))
+				}
+			}
+			settings[key] = items
+		default:
+			settings[key] = yamlScalar(value)
+		}
+	}
+	return json.Marshal(settings)
+}
+
+// withoutYAMLComment removes a comment that starts with a # outside quotes.
+func withoutYAMLComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlScalar returns the value of a YAML scalar as a bool, number or string.
+func yamlScalar(s string) interface{} {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if unquoted, err := strconv.Unquote(s); err == nil {
+				return unquoted
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	return s
+}
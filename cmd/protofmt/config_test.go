@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigDiscovery(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".protofmt.yaml": `# settings for all protos
+indent-width: 4
+sort-imports: true
+local-imports:
+  - "acme/"
+  - 'shared/' # also local
+comment-style: line
+`,
+		"internal/.protofmt.json": `{"indent-width": 2, "align-columns": false, "local-imports": ["internal/"]}`,
+		"internal/a.proto":        "",
+		"api/b.proto":             "",
+	})
+	finder := &configFinder{configs: map[string]*config{}, paths: map[string]string{}}
+
+	opts, used, err := finder.optionsFor(filepath.Join(root, "internal/a.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := used, []string{filepath.Join(root, ".protofmt.yaml"), filepath.Join(root, "internal/.protofmt.json")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if opts.IndentWidth != 2 || opts.AlignColumns || !opts.SortImports || !reflect.DeepEqual(opts.LocalImportPrefixes, []string{"internal/"}) {
+		t.Errorf("got %#v", opts)
+	}
+
+	opts, _, err = finder.optionsFor(filepath.Join(root, "api/b.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.IndentWidth != 4 || !opts.AlignColumns || !reflect.DeepEqual(opts.LocalImportPrefixes, []string{"acme/", "shared/"}) {
+		t.Errorf("got %#v", opts)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, each := range []map[string]string{
+		{".protofmt.yaml": "indent: 2\n"},
+		{".protofmt.yaml": "comment-style: fancy\n"},
+		{".protofmt.yaml": "sort:\n  imports: true\n"},
+		{".protofmt.yaml": "canonical: true\n", ".protofmt.json": "{}"},
+	} {
+		root := t.TempDir()
+		writeFiles(t, root, each)
+		finder := &configFinder{configs: map[string]*config{}, paths: map[string]string{}}
+		if _, _, err := finder.optionsFor(filepath.Join(root, "a.proto")); err == nil {
+			t.Errorf("%v: expected error", each)
+		}
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{".protofmt.json": `{"max-width": 100}`})
+	buf := new(bytes.Buffer)
+	if err := printConfig(buf, root); err != nil {
+		t.Fatal(err)
+	}
+	for _, each := range []string{
+		"# using " + filepath.Join(root, ".protofmt.json") + "\n",
+		`"max-width": 100,`,
+		`"comment-style": "preserve",`,
+		`"local-imports": [],`,
+	} {
+		if !strings.Contains(buf.String(), each) {
+			t.Errorf("missing %q in\n%s", each, buf.String())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines printed around each change.
+const diffContext = 3
+
+// edit is a single line operation of a diff: ' ' keeps, '-' deletes and '+' inserts.
+type edit struct {
+	op   byte
+	line string
+}
+
+// unifiedDiff returns the changes to get from source to formatted in unified format, or an empty string if both are equal.
+func unifiedDiff(filename string, source, formatted []byte) string {
+	if bytes.Equal(source, formatted) {
+		return ""
+	}
+	edits := diffLines(splitLines(source), splitLines(formatted))
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "--- a/%s\n+++ b/%s\n", filename, filename)
+	// oldAt and newAt hold the line index in source and formatted before each edit
+	oldAt, newAt := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, each := range edits {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if each.op != '+' {
+			oldAt[i+1]++
+		}
+		if each.op != '-' {
+			newAt[i+1]++
+		}
+	}
+	for start := 0; start < len(edits); {
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		// extend the hunk while the next change is close enough to share context
+		end := first
+		for {
+			for end < len(edits) && edits[end].op != ' ' {
+				end++
+			}
+			next := end
+			for next < len(edits) && edits[next].op == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		from, to := first-diffContext, end+diffContext
+		if from < start {
+			from = start
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n",
+			hunkRange(oldAt[from], oldAt[to]-oldAt[from]),
+			hunkRange(newAt[from], newAt[to]-newAt[from]))
+		for _, each := range edits[from:to] {
+			buf.WriteByte(each.op)
+			buf.WriteString(each.line)
+			if !strings.HasSuffix(each.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return buf.String()
+}
+
+// hunkRange returns the start,count of a hunk header. Lines are numbered from 1.
+func hunkRange(at, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", at)
+	}
+	return fmt.Sprintf("%d,%d", at+1, count)
+}
+
+// splitLines returns the lines of data, each including its line terminator.
+func splitLines(data []byte) (lines []string) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return
+}
+
+// diffLines computes the edits to get from lines a to lines b.
+// Lines that are unique in both a and b are used as anchors; the regions between anchors
+// are matched at their ends and replaced in the middle. This is not always a minimal
+// diff but it takes O(n log n) time, which matters for large files.
+func diffLines(a, b []string) (edits []edit) {
+	anchors := uniqueCommonLines(a, b)
+	// sentinel anchor to process the tail
+	anchors = append(anchors, [2]int{len(a), len(b)})
+	i, j := 0, 0
+	for _, each := range anchors {
+		// keep equal lines at the start of the region
+		for i < each[0] && j < each[1] && a[i] == b[j] {
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		}
+		// keep equal lines at the end of the region
+		ei, ej := each[0], each[1]
+		for ei > i && ej > j && a[ei-1] == b[ej-1] {
+			ei--
+			ej--
+		}
+		for ; i < ei; i++ {
+			edits = append(edits, edit{'-', a[i]})
+		}
+		for ; j < ej; j++ {
+			edits = append(edits, edit{'+', b[j]})
+		}
+		for ; i < each[0]; i, j = i+1, j+1 {
+			edits = append(edits, edit{' ', a[i]})
+		}
+		if i < len(a) && j < len(b) {
+			// the anchor itself
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		}
+	}
+	return
+}
+
+// uniqueCommonLines returns index pairs of lines that occur exactly once in both a and b,
+// reduced to the longest sequence that is increasing in both.
+func uniqueCommonLines(a, b []string) [][2]int {
+	type counts struct{ inA, inB, indexA, indexB int }
+	lines := map[string]*counts{}
+	for i, each := range a {
+		c, ok := lines[each]
+		if !ok {
+			c = new(counts)
+			lines[each] = c
+		}
+		c.inA++
+		c.indexA = i
+	}
+	for j, each := range b {
+		if c, ok := lines[each]; ok {
+			c.inB++
+			c.indexB = j
+		}
+	}
+	pairs := [][2]int{}
+	for _, c := range lines {
+		if c.inA == 1 && c.inB == 1 {
+			pairs = append(pairs, [2]int{c.indexA, c.indexB})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	// patience sorting to find the longest increasing subsequence of indexes in b
+	tops := []int{}                     // index into pairs of the top card of each pile
+	previous := make([]int, len(pairs)) // index into pairs of the card below on the previous pile
+	for p, each := range pairs {
+		pile := sort.Search(len(tops), func(t int) bool { return pairs[tops[t]][1] > each[1] })
+		previous[p] = -1
+		if pile > 0 {
+			previous[p] = tops[pile-1]
+		}
+		if pile == len(tops) {
+			tops = append(tops, p)
+		} else {
+			tops[pile] = p
+		}
+	}
+	result := make([][2]int, len(tops))
+	if len(tops) > 0 {
+		for i, p := len(tops)-1, tops[len(tops)-1]; i >= 0; i, p = i-1, previous[p] {
+			result[i] = pairs[p]
+		}
+	}
+	return result
+}
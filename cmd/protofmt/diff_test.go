@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	source := `syntax = "proto3";
+package test;
+message A {
+    int32 id = 1;
+}
+enum E {}
+message B {}
+message C {}
+message D {}
+message F {}
+message G {
+    string name = 1;
+}`
+	formatted := `syntax = "proto3";
+
+package test;
+
+message A {
+  int32 id = 1;
+}
+
+enum E {}
+
+message B {}
+
+message C {}
+
+message D {}
+
+message F {}
+
+message G {
+  string name = 1;
+}
+`
+	want := `--- a/test.proto
++++ b/test.proto
+@@ -1,13 +1,21 @@
+ syntax = "proto3";
++
+ package test;
++
+ message A {
+-    int32 id = 1;
+-}
++  int32 id = 1;
++}
++
+ enum E {}
++
+ message B {}
++
+ message C {}
++
+ message D {}
++
+ message F {}
++
+ message G {
+-    string name = 1;
+-}
+\ No newline at end of file
++  string name = 1;
++}
+`
+	if got := unifiedDiff("test.proto", []byte(source), []byte(formatted)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnifiedDiffEqual(t *testing.T) {
+	if got := unifiedDiff("test.proto", []byte("a\n"), []byte("a\n")); got != "" {
+		t.Errorf("got [%s] want empty", got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// globList is a flag.Value that collects repeated glob patterns.
+type globList []string
+
+func (g *globList) String() string { return strings.Join(*g, ",") }
+
+func (g *globList) Set(value string) error {
+	for _, each := range strings.Split(value, ",") {
+		if pattern := strings.TrimSpace(each); len(pattern) > 0 {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return err
+			}
+			*g = append(*g, pattern)
+		}
+	}
+	return nil
+}
+
+// matches returns true if any pattern matches the slash separated path, its base name
+// or one of its leading directories.
+func (g globList) matches(path string) bool {
+	path = filepath.ToSlash(path)
+	for _, pattern := range g {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		for prefix := path; prefix != "." && prefix != "/" && prefix != ""; prefix = filepath.ToSlash(filepath.Dir(prefix)) {
+			if ok, _ := filepath.Match(pattern, prefix); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collectFiles returns the files to format for the command arguments in a stable order.
+// Files are taken as is. Directories are walked recursively and their files are
+// selected using the include and exclude patterns, relative to the directory.
+func collectFiles(args []string, include, exclude globList) ([]string, error) {
+	files := []string{}
+	for _, each := range args {
+		info, err := os.Stat(each)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, each)
+			continue
+		}
+		root := each
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				return nil
+			}
+			if exclude.matches(rel) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && include.matches(rel) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
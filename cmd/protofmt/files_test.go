@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCollectFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, each := range []string{
+		"api/v1/service.proto",
+		"api/v1/notes.txt",
+		"api/types.proto",
+		"vendor/google/protobuf/empty.proto",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(each))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte{}, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	explicit := filepath.Join(root, "api/v1/notes.txt")
+	files, err := collectFiles([]string{root, explicit}, globList{"*.proto"}, globList{"vendor/google"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(root, "api/types.proto"),
+		filepath.Join(root, "api/v1/service.proto"),
+		explicit,
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %v want %v", files, want)
+	}
+}
+
+func TestGlobListMatches(t *testing.T) {
+	g := globList{}
+	if err := g.Set("google, *_test.proto"); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		"google/protobuf/empty.proto": true,
+		"api/googleapis/http.proto":   false,
+		"api/v1/service_test.proto":   true,
+		"api/v1/service.proto":        false,
+	} {
+		if got := g.matches(path); got != want {
+			t.Errorf("%s: got %v want %v", path, got, want)
+		}
+	}
+}
@@ -1,4 +1,5 @@
 go install
 protofmt unittest_proto2.proto > unittest_proto2_formatted.proto
 protofmt unittest_proto3.proto > unittest_proto3_formatted.proto
-protofmt unittest_proto3_arena.proto > unittest_proto3_arena_formatted.proto
\ No newline at end of file
+protofmt unittest_proto3_arena.proto > unittest_proto3_arena_formatted.proto
+protofmt unittest_edition2023.proto > unittest_edition2023_formatted.proto
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedFiles returns the paths of the files in the git repository of a directory that are added or modified
+// between a revision and the working tree, including untracked files that are not ignored.
+// It uses the git binary. Paths are absolute, with symbolic links evaluated.
+func changedFiles(dir, rev string) (map[string]bool, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+	// renames are reported as added files
+	diff, err := git(dir, "diff", "--name-only", "--no-renames", "--diff-filter=AM", "-z", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(dir, "ls-files", "--others", "--exclude-standard", "--full-name", "-z")
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string]bool{}
+	for _, each := range bytes.Split(append(diff, untracked...), []byte{0}) {
+		if len(each) > 0 {
+			changed[realPath(filepath.Join(root, filepath.FromSlash(string(each))))] = true
+		}
+	}
+	return changed, nil
+}
+
+// git runs a git command in a directory and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// onlyChanged returns the files, in the same order, that are changed.
+func onlyChanged(files []string, changed map[string]bool) []string {
+	list := []string{}
+	for _, each := range files {
+		if changed[realPath(each)] {
+			list = append(list, each)
+		}
+	}
+	return list
+}
+
+// realPath returns the absolute path of a file with symbolic links evaluated, if possible.
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return path
+}
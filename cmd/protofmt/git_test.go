@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) {
+		if _, err := git(root, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("api/modified.proto", "syntax = \"proto3\";\n")
+	write("api/unchanged.proto", "syntax = \"proto3\";\n")
+	write("api/removed.proto", "syntax = \"proto3\";\n")
+	write("api/renamed.proto", "syntax = \"proto3\";\nmessage Renamed {}\n")
+	write(".gitignore", "ignored.proto\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "initial")
+
+	write("api/modified.proto", "syntax = \"proto3\";\npackage api;\n")
+	write("api/staged.proto", "syntax = \"proto3\";\n")
+	write("api/v1/untracked.proto", "syntax = \"proto3\";\n")
+	write("api/notes.txt", "not a proto file\n")
+	write("ignored.proto", "syntax = \"proto3\";\n")
+	if err := os.Remove(filepath.Join(root, "api/removed.proto")); err != nil {
+		t.Fatal(err)
+	}
+	run("mv", "api/renamed.proto", "api/moved.proto")
+	run("add", "api/staged.proto")
+
+	changed, err := changedFiles(root, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := collectFiles([]string{root}, globList{"*.proto"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(root, "api/modified.proto"),
+		filepath.Join(root, "api/moved.proto"),
+		filepath.Join(root, "api/staged.proto"),
+		filepath.Join(root, "api/v1/untracked.proto"),
+	}
+	if got := onlyChanged(files, changed); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if _, err := changedFiles(root, "no-such-revision"); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+}
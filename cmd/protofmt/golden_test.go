@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+)
+
+// TestGoldenFiles checks that formatting each source file gives its _formatted version.
+// Run format.sh to regenerate the formatted files after a change of style.
+func TestGoldenFiles(t *testing.T) {
+	for _, each := range []string{
+		"unittest_proto2",
+		"unittest_proto3",
+		"unittest_proto3_arena",
+		"unittest_edition2023",
+	} {
+		t.Run(each, func(t *testing.T) {
+			source, err := os.Open(each + ".proto")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer source.Close()
+			want, err := ioutil.ReadFile(each + "_formatted.proto")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(bytes.Buffer)
+			if err := format(each+".proto", source, got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("formatted source differs:\n%s", unifiedDiff(each+"_formatted.proto", want, got.Bytes()))
+			}
+			if err := protofmt.Verify(each+"_formatted.proto", want, protofmt.DefaultFormatterOptions()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
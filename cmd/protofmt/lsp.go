@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+)
+
+// JSON-RPC error codes used by the language server.
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspRequestFailed  = -32803
+)
+
+// lspServer implements the Language Server Protocol for formatting and diagnostics.
+// It keeps the text of open documents, which are synchronized in full.
+type lspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	options   func(filename string) (protofmt.FormatterOptions, error)
+	documents map[string]string
+	shutdown  bool
+}
+
+// malformedMessageError tells that the body of a message is not a valid request; reading can go on.
+type malformedMessageError struct {
+	err error
+}
+
+func (e *malformedMessageError) Error() string {
+	return "malformed message: " + e.err.Error()
+}
+
+// lspRequest is a request or notification sent by the client.
+type lspRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspDocumentParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Range lspRange `json:"range"`
+}
+
+// serveLSP runs a language server that reads from in and writes to out until the client sends exit.
+// It returns the exit code of the process.
+// The options function returns the options to format a file.
+func serveLSP(in io.Reader, out io.Writer, options func(filename string) (protofmt.FormatterOptions, error)) int {
+	s := &lspServer{in: bufio.NewReader(in), out: out, options: options, documents: map[string]string{}}
+	for {
+		req, err := s.read()
+		if malformed, ok := err.(*malformedMessageError); ok {
+			// the id of the request is unknown, so the reply has none
+			s.replyError(new(lspRequest), lspParseError, malformed.Error())
+			continue
+		}
+		if err != nil {
+			// the client went away without asking to exit
+			return 1
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		s.handle(req)
+	}
+}
+
+// handle dispatches a request or notification.
+func (s *lspServer) handle(req *lspRequest) {
+	params := new(lspDocumentParams)
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, params); err != nil {
+			s.replyError(req, lspInvalidParams, err.Error())
+			return
+		}
+	}
+	uri := params.TextDocument.URI
+	switch req.Method {
+	case "initialize":
+		s.reply(req, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":                1, // full
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "protofmt"},
+		})
+	case "initialized":
+	case "shutdown":
+		s.shutdown = true
+		s.reply(req, nil)
+	case "textDocument/didOpen":
+		s.documents[uri] = params.TextDocument.Text
+		s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[uri] = params.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.documents, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": []lspDiagnostic{}})
+	case "textDocument/formatting":
+		s.format(req, uri, -1, -1)
+	case "textDocument/rangeFormatting":
+		from, to := params.Range.Start.Line+1, params.Range.End.Line+1
+		// a selection that ends at the start of a line does not include that line
+		if params.Range.End.Character == 0 && to > from {
+			to--
+		}
+		s.format(req, uri, from, to)
+	default:
+		// notifications that are not supported are ignored
+		if len(req.ID) > 0 {
+			s.replyError(req, lspMethodNotFound, "method not supported: "+req.Method)
+		}
+	}
+}
+
+// format replies with the edits that format a document or, if lines are given, the declarations on these lines.
+func (s *lspServer) format(req *lspRequest, uri string, from, to int) {
+	text, ok := s.documents[uri]
+	if !ok {
+		s.replyError(req, lspRequestFailed, "document is not open: "+uri)
+		return
+	}
+	source := []byte(text)
+	options, err := s.options(filenameOf(uri))
+	if err != nil {
+		s.replyError(req, lspRequestFailed, err.Error())
+		return
+	}
+	var edits []protofmt.TextEdit
+	if from == -1 {
+		formatted, err := protofmt.Source(source, options)
+		if err != nil {
+			s.replyError(req, lspRequestFailed, err.Error())
+			return
+		}
+		if !bytes.Equal(source, formatted) {
+			edits = append(edits, protofmt.TextEdit{Start: 0, End: len(source), Text: string(formatted)})
+		}
+	} else {
+		if edits, err = protofmt.FormatRange(filenameOf(uri), source, from, to, options); err != nil {
+			s.replyError(req, lspRequestFailed, err.Error())
+			return
+		}
+	}
+	result := []lspTextEdit{}
+	for _, each := range edits {
+		result = append(result, lspTextEdit{
+			Range:   lspRange{Start: positionOf(source, each.Start), End: positionOf(source, each.End)},
+			NewText: each.Text,
+		})
+	}
+	s.reply(req, result)
+}
+
+// publishDiagnostics sends the parse error of a document, if any.
+func (s *lspServer) publishDiagnostics(uri string) {
+	diagnostics := []lspDiagnostic{}
+	_, err := protofmt.Source([]byte(s.documents[uri]), protofmt.DefaultFormatterOptions())
+	if syntaxErr, ok := err.(*protofmt.SyntaxError); ok {
+		at := lspPosition{Line: syntaxErr.Line - 1, Character: syntaxErr.Column - 1}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{Start: at, End: at},
+			Severity: 1, // error
+			Source:   "protofmt",
+			Message:  syntaxErr.Message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics})
+}
+
+// filenameOf returns the path of a file URI, or the URI itself if it has no path.
+func filenameOf(uri string) string {
+	if u, err := url.Parse(uri); err == nil && len(u.Path) > 0 {
+		return u.Path
+	}
+	return uri
+}
+
+// positionOf returns the line and UTF-16 based character of an offset in the source.
+func positionOf(source []byte, offset int) lspPosition {
+	before := source[:offset]
+	line := bytes.Count(before, []byte("\n"))
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	character := 0
+	for rest := before[lineStart:]; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		character += len(utf16.Encode([]rune{r}))
+		rest = rest[size:]
+	}
+	return lspPosition{Line: line, Character: character}
+}
+
+// read reads the next message, which is preceded by headers including its Content-Length.
+func (s *lspServer) read() (*lspRequest, error) {
+	length := -1
+	for {
+		header, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header = strings.TrimSpace(header)
+		if len(header) == 0 {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(header), "content-length:") {
+			if length, err = strconv.Atoi(strings.TrimSpace(header[len("content-length:"):])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	req := new(lspRequest)
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, &malformedMessageError{err: err}
+	}
+	return req, nil
+}
+
+func (s *lspServer) reply(req *lspRequest, result interface{}) {
+	s.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  interface{}     `json:"result"`
+	}{"2.0", req.ID, result})
+}
+
+func (s *lspServer) replyError(req *lspRequest, code int, message string) {
+	s.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   lspError        `json:"error"`
+	}{"2.0", req.ID, lspError{Code: code, Message: message}})
+}
+
+func (s *lspServer) notify(method string, params interface{}) {
+	s.write(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}{"2.0", method, params})
+}
+
+// write sends a message preceded by its Content-Length header.
+func (s *lspServer) write(message interface{}) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
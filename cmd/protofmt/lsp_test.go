@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/proto-contrib/pkg/protofmt"
+)
+
+// lspClient is an in-process client that talks to a server running in a goroutine.
+type lspClient struct {
+	t      *testing.T
+	in     *bufio.Reader
+	out    io.WriteCloser
+	nextID int
+	exit   chan int
+}
+
+func newLSPClient(t *testing.T) *lspClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &lspClient{t: t, in: bufio.NewReader(clientIn), out: clientOut, exit: make(chan int, 1)}
+	go func() {
+		c.exit <- serveLSP(serverIn, serverOut, func(string) (protofmt.FormatterOptions, error) {
+			return protofmt.DefaultFormatterOptions(), nil
+		})
+		serverOut.Close()
+	}()
+	return c
+}
+
+// send writes a request (if withID) or notification and returns its id.
+func (c *lspClient) send(method string, params interface{}, withID bool) int {
+	message := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if withID {
+		c.nextID++
+		message["id"] = c.nextID
+	}
+	body, _ := json.Marshal(message)
+	fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return c.nextID
+}
+
+// receive reads the next message from the server.
+func (c *lspClient) receive() map[string]interface{} {
+	length := 0
+	for {
+		header, err := c.in.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if header = strings.TrimSpace(header); len(header) == 0 {
+			break
+		}
+		length, _ = strconv.Atoi(strings.TrimPrefix(header, "Content-Length: "))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.in, body); err != nil {
+		c.t.Fatal(err)
+	}
+	message := map[string]interface{}{}
+	if err := json.Unmarshal(body, &message); err != nil {
+		c.t.Fatal(err)
+	}
+	return message
+}
+
+// call sends a request and returns the message received as its response.
+func (c *lspClient) call(method string, params interface{}) map[string]interface{} {
+	id := c.send(method, params, true)
+	response := c.receive()
+	if got, want := response["id"], float64(id); got != want {
+		c.t.Fatalf("got id %v want %v", got, want)
+	}
+	return response
+}
+
+func document(uri string) map[string]interface{} {
+	return map[string]interface{}{"uri": uri}
+}
+
+func TestLanguageServer(t *testing.T) {
+	c := newLSPClient(t)
+	const uri = "file:///tmp/a.proto"
+
+	capabilities := c.call("initialize", map[string]interface{}{})["result"].(map[string]interface{})["capabilities"]
+	if got := capabilities.(map[string]interface{})["documentRangeFormattingProvider"]; got != true {
+		t.Errorf("got %v want range formatting", got)
+	}
+	c.send("initialized", map[string]interface{}{}, false)
+
+	// a parse error is published as diagnostic
+	c.send("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{
+		"uri": uri, "languageId": "proto", "version": 1, "text": "message A {\n  int32 id = ;\n}\n"}}, false)
+	diagnostics := c.receive()
+	if got, want := diagnostics["method"], "textDocument/publishDiagnostics"; got != want {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	list := diagnostics["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(list) != 1 {
+		t.Fatalf("got %v want one diagnostic", list)
+	}
+	start := list[0].(map[string]interface{})["range"].(map[string]interface{})["start"]
+	if got, want := start, map[string]interface{}{"line": float64(1), "character": float64(13)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got := c.call("textDocument/formatting", map[string]interface{}{"textDocument": document(uri)}); got["error"] == nil {
+		t.Errorf("got %v want error", got)
+	}
+
+	// fixing the error clears the diagnostics
+	c.send("textDocument/didChange", map[string]interface{}{"textDocument": document(uri),
+		"contentChanges": []interface{}{map[string]interface{}{"text": "message A {\nint32 id = 1;\n}\nmessage   B {}\n"}}}, false)
+	list = c.receive()["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(list) != 0 {
+		t.Errorf("got %v want no diagnostics", list)
+	}
+
+	edits := c.call("textDocument/formatting", map[string]interface{}{"textDocument": document(uri)})["result"]
+	want := []interface{}{map[string]interface{}{
+		"range": map[string]interface{}{
+			"start": map[string]interface{}{"line": float64(0), "character": float64(0)},
+			"end":   map[string]interface{}{"line": float64(4), "character": float64(0)},
+		},
+		"newText": "message A {\n  int32 id = 1;\n}\n\nmessage B {}\n",
+	}}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("got %v want %v", edits, want)
+	}
+
+	// only the selected field
+	edits = c.call("textDocument/rangeFormatting", map[string]interface{}{"textDocument": document(uri),
+		"range": map[string]interface{}{
+			"start": map[string]interface{}{"line": 1, "character": 0},
+			"end":   map[string]interface{}{"line": 2, "character": 0},
+		}})["result"]
+	want = []interface{}{map[string]interface{}{
+		"range": map[string]interface{}{
+			"start": map[string]interface{}{"line": float64(1), "character": float64(0)},
+			"end":   map[string]interface{}{"line": float64(2), "character": float64(0)},
+		},
+		"newText": "  int32 id = 1;\n",
+	}}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("got %v want %v", edits, want)
+	}
+
+	if got := c.call("textDocument/hover", map[string]interface{}{"textDocument": document(uri)}); got["error"] == nil {
+		t.Errorf("got %v want error", got)
+	}
+
+	// a malformed message is answered with a parse error and the server keeps serving
+	fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n%s", len("{not json"), "{not json")
+	malformed := c.receive()
+	if got, want := malformed["error"].(map[string]interface{})["code"], float64(-32700); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got := malformed["id"]; got != nil {
+		t.Errorf("got id %v want null", got)
+	}
+	c.call("shutdown", nil)
+	c.send("exit", nil, false)
+	if got := <-c.exit; got != 0 {
+		t.Errorf("got exit code %d want 0", got)
+	}
+}
+
+func TestPositionOf(t *testing.T) {
+	source := []byte("a\n\U0001F600b\n")
+	if got, want := positionOf(source, 7), (lspPosition{Line: 1, Character: 3}); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+	if got, want := positionOf(source, len(source)), (lspPosition{Line: 2, Character: 0}); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -25,69 +25,305 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
+	"strings"
 
 	"github.com/emicklei/proto"
 	"github.com/emicklei/proto-contrib/pkg/protofmt"
 )
 
 var (
-	oOverwrite = flag.Bool("w", false, "write result to (source) file instead of stdout")
-	oDebug     = flag.Bool("d", false, "debug mode")
+	oOverwrite    = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	oList         = flag.Bool("l", false, "list files whose formatting differs from protofmt's")
+	oDiff         = flag.Bool("d", false, "display diffs instead of rewriting files")
+	oVerify       = flag.Bool("verify", false, "check that formatting twice gives the same result and keeps all elements and comments")
+	oWorkers      = flag.Int("p", runtime.NumCPU(), "number of files formatted in parallel")
+	oInclude      = globList{}
+	oExclude      = globList{}
+	oSortImports  = flag.Bool("sort-imports", false, "sort, group and de-duplicate imports")
+	oLocalImports = flag.String("local-imports", "", "comma separated filename prefixes of imports grouped as local")
+	oCanonical    = flag.Bool("canonical", false, "reorder top-level elements into the canonical order")
+	oSortFields   = flag.Bool("sort-fields", false, "reorder message elements: options, nested types, fields by number")
+	oMaxWidth     = flag.Int("max-width", 0, "wrap field options and rpc signatures of lines longer than this, 0 means no limit")
+	oPrintConfig  = flag.Bool("print-config", false, "print the settings used for each path, from configuration files and flags")
+	oKeepBlanks   = flag.Bool("keep-blank-lines", false, "keep a single empty line wherever the source has one or more between fields, values or rpc-s")
+	oCommentStyle = flag.String("comment-style", "preserve", "write comments as in the source (preserve), as // lines (line) or as /* */ blocks (block)")
+	oCommentWidth = flag.Int("comment-width", 0, "reflow the paragraphs of // documentation to lines no longer than this, 0 means no reflow")
+	oNormalize    = flag.Bool("normalize-comments", false, "write a single space between // and the text of comments")
+	oLiterals     = flag.Bool("normalize-literals", false, "write strings between double quotes with the same escaping everywhere")
+	oDecimal      = flag.Bool("decimal-numbers", false, "with -normalize-literals, write hexadecimal, octal and exponent numbers as decimals")
+	oChangedSince = flag.String("changed-since", "", "only format the files that are added or modified since this git revision, including untracked files")
+	oFixNames     = flag.Bool("fix-names", false, "rename types, fields and enum values to the naming conventions and rewrite references in all given files")
 )
 
+func init() {
+	flag.Var(&oInclude, "include", "glob pattern of files to format in directories, can be repeated (default *.proto)")
+	flag.Var(&oExclude, "exclude", "glob pattern of files or directories to skip in directories, can be repeated")
+}
+
+// result holds the outcome of formatting one file.
+type result struct {
+	filename string
+	stdout   []byte
+	changed  bool
+	err      error
+}
+
 // go run *.go unformatted.proto
 func main() {
 	flag.Parse()
-	if len(flag.Args()) == 0 {
+	// renames must reach the references in all files, also in those that are not changed
+	if *oFixNames && len(*oChangedSince) > 0 {
+		println("-fix-names cannot be used with -changed-since because references in unchanged files would not be renamed")
+		os.Exit(1)
+	}
+	args := flag.Args()
+	if len(args) == 0 && len(*oChangedSince) > 0 {
+		args = []string{"."}
+	}
+	if len(args) == 0 {
 		flag.Usage()
 		os.Exit(0)
 	}
-	exitCode := 0
-	for _, each := range flag.Args() {
-		if err := readFormatWrite(each); err != nil {
+	if args[0] == "lsp" {
+		os.Exit(serveLSP(os.Stdin, os.Stdout, formatterOptions))
+	}
+	if *oPrintConfig {
+		for _, each := range args {
+			if err := printConfig(os.Stdout, each); err != nil {
+				println(err.Error())
+				os.Exit(1)
+			}
+		}
+		os.Exit(0)
+	}
+	if len(oInclude) == 0 {
+		oInclude = globList{"*.proto"}
+	}
+	files, err := collectFiles(args, oInclude, oExclude)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	if len(*oChangedSince) > 0 {
+		changed, err := changedFiles("", *oChangedSince)
+		if err != nil {
 			println(err.Error())
-			exitCode = 1
+			os.Exit(1)
+		}
+		files = onlyChanged(files, changed)
+	}
+	if *oFixNames {
+		if err := fixNames(files); err != nil {
+			println(err.Error())
+			os.Exit(1)
 		}
 	}
+	exitCode := 0
+	formatFiles(files, *oWorkers, func(r result) {
+		os.Stdout.Write(r.stdout)
+		if r.err != nil {
+			println(r.err.Error())
+			exitCode = 1
+		}
+		// in check mode, a file that needs formatting is a failure
+		if r.changed && (*oList || *oDiff) {
+			exitCode = 1
+		}
+	})
 	os.Exit(exitCode)
 }
 
-func readFormatWrite(filename string) error {
-	// open for read
-	file, err := os.Open(filename)
+// formatFiles formats the files using a number of workers.
+// The report function is called for each result in the order of the files.
+func formatFiles(files []string, workers int, report func(r result)) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	results := make([]chan result, len(files))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				stdout := new(bytes.Buffer)
+				changed, err := readFormatWrite(files[i], stdout)
+				results[i] <- result{filename: files[i], stdout: stdout.Bytes(), changed: changed, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range files {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+	for _, each := range results {
+		report(<-each)
+	}
+}
+
+// readFormatWrite formats a file and reports whether the result differs from its source.
+// Anything that must be printed is written to stdout.
+func readFormatWrite(filename string, stdout io.Writer) (bool, error) {
+	source, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if *oVerify {
+		opts, err := formatterOptions(filename)
+		if err != nil {
+			return false, err
+		}
+		return false, protofmt.Verify(filename, source, opts)
 	}
 	// buffer before write
 	buf := new(bytes.Buffer)
-	if err := format(filename, file, buf); err != nil {
-		return err
+	if err := format(filename, bytes.NewReader(source), buf); err != nil {
+		return false, err
+	}
+	changed := !bytes.Equal(source, buf.Bytes())
+	if *oList && changed {
+		fmt.Fprintln(stdout, filename)
+	}
+	if *oDiff && changed {
+		io.WriteString(stdout, unifiedDiff(filename, source, buf.Bytes()))
 	}
 	if *oOverwrite {
-		// write back to input
-		if err := ioutil.WriteFile(filename, buf.Bytes(), os.ModePerm); err != nil {
-			return err
+		if changed {
+			// write back to input
+			if err := ioutil.WriteFile(filename, buf.Bytes(), os.ModePerm); err != nil {
+				return changed, err
+			}
 		}
-	} else {
+	} else if !*oList && !*oDiff {
 		// write to stdout
-		if _, err := io.Copy(os.Stdout, bytes.NewReader(buf.Bytes())); err != nil {
+		if _, err := io.Copy(stdout, bytes.NewReader(buf.Bytes())); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// renamed holds the definitions of the files, by filename, of which the names are fixed.
+var renamed = map[string]*proto.Proto{}
+
+// fixNames parses all files and fixes their names as one set.
+func fixNames(files []string) error {
+	definitions := []*proto.Proto{}
+	for _, each := range files {
+		source, err := ioutil.ReadFile(each)
+		if err != nil {
 			return err
 		}
+		parser := proto.NewParser(bytes.NewReader(source))
+		parser.Filename(each)
+		def, err := parser.Parse()
+		if err != nil {
+			return err
+		}
+		definitions = append(definitions, def)
+	}
+	if err := protofmt.FixNames(definitions...); err != nil {
+		return err
+	}
+	for i, each := range files {
+		renamed[each] = definitions[i]
 	}
 	return nil
 }
 
 func format(filename string, input io.Reader, output io.Writer) error {
-	parser := proto.NewParser(input)
-	parser.Filename(filename)
-	def, err := parser.Parse()
+	// the source is needed to preserve empty lines
+	source, err := ioutil.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	def, ok := renamed[filename]
+	if !ok {
+		parser := proto.NewParser(bytes.NewReader(source))
+		parser.Filename(filename)
+		parsed, err := parser.Parse()
+		if err != nil {
+			return err
+		}
+		def = parsed
+	}
+	opts, err := formatterOptions(filename)
+	if err != nil {
+		return err
+	}
+	f := protofmt.NewFormatterWithOptions(output, opts)
+	f.SetSource(source)
+	f.Format(def)
+	return nil
+}
+
+// flagConfig returns the settings of the flags that are given on the command line.
+func flagConfig() config {
+	c := config{}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "sort-imports":
+			c.SortImports = oSortImports
+		case "local-imports":
+			c.LocalImports = []string{}
+			for _, each := range strings.Split(*oLocalImports, ",") {
+				if prefix := strings.TrimSpace(each); len(prefix) > 0 {
+					c.LocalImports = append(c.LocalImports, prefix)
+				}
+			}
+		case "canonical":
+			c.Canonical = oCanonical
+		case "sort-fields":
+			c.SortFields = oSortFields
+		case "max-width":
+			c.MaxWidth = oMaxWidth
+		case "keep-blank-lines":
+			c.KeepBlankLines = oKeepBlanks
+		case "comment-style":
+			c.CommentStyle = oCommentStyle
+		case "comment-width":
+			c.CommentWidth = oCommentWidth
+		case "normalize-comments":
+			c.NormalizeComments = oNormalize
+		case "normalize-literals":
+			c.NormalizeLiterals = oLiterals
+		case "decimal-numbers":
+			c.DecimalNumbers = oDecimal
+		}
+	})
+	return c
+}
+
+// formatterOptions returns the options to format a file, without the paths of the configuration files.
+func formatterOptions(filename string) (protofmt.FormatterOptions, error) {
+	opts, _, err := configs.optionsFor(filename)
+	return opts, err
+}
+
+// printConfig writes the effective settings for a path and the configuration files they come from.
+func printConfig(w io.Writer, path string) error {
+	opts, used, err := configs.optionsFor(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# %s\n", path)
+	for _, each := range used {
+		fmt.Fprintf(w, "# using %s\n", each)
+	}
+	data, err := json.MarshalIndent(configOf(opts), "", "  ")
 	if err != nil {
 		return err
 	}
-	protofmt.NewFormatter(output, "  ").Format(def) // 2 spaces
+	fmt.Fprintf(w, "%s\n", data)
 	return nil
 }
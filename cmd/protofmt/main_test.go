@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatFilesReportsInOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{}
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("m%d.proto", i))
+		source := fmt.Sprintf("message M%d {\nint32 a = 1;\nstring b = 2;\n}\n", i)
+		if err := ioutil.WriteFile(path, []byte(source), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	i := 0
+	formatFiles(files, 4, func(r result) {
+		if r.err != nil {
+			t.Error(r.err)
+		}
+		if got, want := r.filename, files[i]; got != want {
+			t.Errorf("result %d: got %s want %s", i, got, want)
+		}
+		if want := fmt.Sprintf("message M%d {", i); !bytes.Contains(r.stdout, []byte(want)) {
+			t.Errorf("result %d: output does not contain %q:\n%s", i, want, r.stdout)
+		}
+		i++
+	})
+	if i != len(files) {
+		t.Errorf("got %d results want %d", i, len(files))
+	}
+}
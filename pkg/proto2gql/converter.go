@@ -1,6 +1,9 @@
 package proto2gql
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+)
 
 type Converter struct {
 	noPrefix   bool
@@ -11,6 +14,16 @@ func (c *Converter) NewTypeName(scope *Scope, name string) string {
 	return scope.convertedPackageName + strings.Join(scope.path, "") + name
 }
 
+// EntryTypeName returns the name of the type generated for the entries of a map field.
+func (c *Converter) EntryTypeName(scope *Scope, message, field string) string {
+	return c.NewTypeName(scope, message) + camelCase(field) + "Entry"
+}
+
+// OneofTypeName returns the name of the union type generated for a oneof.
+func (c *Converter) OneofTypeName(scope *Scope, message, oneof string) string {
+	return c.NewTypeName(scope, message) + camelCase(oneof)
+}
+
 func (c *Converter) OriginalTypeName(scope *Scope, name string) string {
 	switch len(scope.path) {
 	case 0:
@@ -52,3 +65,31 @@ func (c *Converter) PackageName(parts []string) string {
 
 	return name
 }
+
+// camelCase returns a snake_case name in CamelCase.
+func camelCase(name string) string {
+	var res string
+
+	for _, segment := range strings.Split(name, "_") {
+		res += strings.Title(segment)
+	}
+
+	return res
+}
+
+// lowerCamelCase returns a CamelCase or snake_case name in lowerCamelCase.
+// A leading acronym is lowered as a whole, so HTTPStatus becomes httpStatus.
+func lowerCamelCase(name string) string {
+	runes := []rune(camelCase(name))
+
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		// the last upper case letter of an acronym starts the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+
+		runes[i] = unicode.ToLower(runes[i])
+	}
+
+	return string(runes)
+}
@@ -12,6 +12,7 @@ type (
 		originalName         string
 		convertedPackageName string
 		convertedName        string
+		enum                 bool
 	}
 
 	Scope struct {
@@ -74,6 +75,38 @@ func (s *Scope) AddLocalType(name string) {
 	}
 }
 
+// AddLocalEnum adds a local type that is an enum.
+func (s *Scope) AddLocalEnum(name string) {
+	s.AddLocalType(name)
+
+	s.types[s.converter.OriginalTypeName(s, name)].enum = true
+}
+
+// IsEnum returns true if a reference resolves to a local enum.
+func (s *Scope) IsEnum(ref string) bool {
+	local := s.localType(ref)
+
+	return local != nil && local.enum
+}
+
+// IsLocal returns true if a reference resolves to a local message or enum.
+func (s *Scope) IsLocal(ref string) bool {
+	return s.localType(ref) != nil
+}
+
+// localType returns the local type a reference resolves to, nil if there is none.
+func (s *Scope) localType(ref string) *Type {
+	convertedName := s.ResolveConvertedTypeName(ref)
+
+	for _, each := range s.types {
+		if each.convertedName == convertedName {
+			return each
+		}
+	}
+
+	return nil
+}
+
 func (s *Scope) AddImportedType(filename string) {
 	dir := path.Dir(filename)
 	separator := string(filepath.Separator)
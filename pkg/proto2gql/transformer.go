@@ -2,6 +2,7 @@ package proto2gql
 
 import (
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
 
@@ -11,6 +12,12 @@ import (
 type (
 	Filter = func(typeName string) bool
 
+	// OneofStrategy decides how the fields of a oneof are converted.
+	OneofStrategy int
+
+	// InputStrategy decides for which messages input types are generated.
+	InputStrategy int
+
 	ExternalPackage struct {
 		url      string
 		resolved bool
@@ -23,9 +30,29 @@ type (
 		pkgAliases map[string]string
 		noPrefix   bool
 		filter     Filter
+		oneofs     OneofStrategy
+		inputs     InputStrategy
+		queries    []string
+		roots      map[string]bool
+		written    map[string]bool
 	}
 )
 
+const (
+	// FlattenOneofs converts the fields of a oneof into nullable fields of the message.
+	FlattenOneofs OneofStrategy = iota
+	// UnionOneofs converts the message fields of a oneof into one field of a generated union type.
+	// Other fields, which cannot be members of a union, are flattened.
+	UnionOneofs
+)
+
+const (
+	// RequestInputs generates input types for the requests of RPCs and the messages used by them.
+	RequestInputs InputStrategy = iota
+	// AllInputs generates input types for all messages.
+	AllInputs
+)
+
 func bypassFilter(_ string) bool {
 	return true
 }
@@ -38,6 +65,11 @@ func NewTransformer(out io.Writer, opts ...func(transformer *Transformer)) *Tran
 		make(map[string]string),
 		false,
 		bypassFilter,
+		FlattenOneofs,
+		RequestInputs,
+		[]string{"Get", "List"},
+		make(map[string]bool),
+		make(map[string]bool),
 	}
 
 	for _, opt := range opts {
@@ -75,6 +107,20 @@ func (t *Transformer) SetFilter(filter Filter) {
 	}
 }
 
+func (t *Transformer) SetOneofStrategy(strategy OneofStrategy) {
+	t.oneofs = strategy
+}
+
+func (t *Transformer) SetInputStrategy(strategy InputStrategy) {
+	t.inputs = strategy
+}
+
+// SetQueryPrefixes sets the prefixes of the names of RPCs that are queries, Get and List by default.
+// RPCs with the option idempotency_level = NO_SIDE_EFFECTS are queries too; other RPCs are mutations.
+func (t *Transformer) SetQueryPrefixes(prefixes ...string) {
+	t.queries = prefixes
+}
+
 func (t *Transformer) Transform(input io.Reader) error {
 	parser := proto.NewParser(input)
 	parser.Filename(t.filename)
@@ -90,7 +136,18 @@ func (t *Transformer) Transform(input io.Reader) error {
 		pkgAliases: t.pkgAliases,
 	}, t.filter)
 
+	visitor.oneofs = t.oneofs
+	visitor.queries = t.queries
+	visitor.roots = t.roots
+	visitor.written = t.written
+
+	// only messages can be members of a union, so enums declared after their use must be known first
+	if t.oneofs == UnionOneofs {
+		declareTypes(def, visitor)
+	}
+
 	toDownload := make(map[string]*ExternalPackage)
+	services := make([]*proto.Service, 0, 1)
 
 	for _, element := range def.Elements {
 
@@ -108,6 +165,11 @@ func (t *Transformer) Transform(input io.Reader) error {
 					}
 				}
 			}
+		case *proto.Service:
+			// services are transformed last, when all types they use are in a scope
+			services = append(services, element)
+
+			continue
 		}
 
 		element.Accept(visitor)
@@ -115,6 +177,14 @@ func (t *Transformer) Transform(input io.Reader) error {
 		visitor.Flush(t.out)
 	}
 
+	t.transformInputs(def, services, visitor)
+
+	for _, service := range services {
+		service.Accept(visitor)
+
+		visitor.Flush(t.out)
+	}
+
 	if len(toDownload) > 0 {
 		packages := make([]*ExternalPackage, 0, len(toDownload))
 
@@ -128,6 +198,57 @@ func (t *Transformer) Transform(input io.Reader) error {
 	return nil
 }
 
+// declareTypes adds the types of a definition to the scope of a visitor, transforming them without output.
+func declareTypes(def *proto.Proto, visitor *Visitor) {
+	for _, element := range def.Elements {
+		if _, ok := element.(*proto.Service); ok == false {
+			element.Accept(visitor)
+
+			visitor.Flush(ioutil.Discard)
+		}
+	}
+}
+
+// transformInputs writes the input types of the messages of a definition, after all its types are in a scope.
+func (t *Transformer) transformInputs(def *proto.Proto, services []*proto.Service, visitor *Visitor) {
+	inputs := newInputTypes(t.inputs == AllInputs)
+
+	for _, service := range services {
+		for _, element := range service.Elements {
+			if rpc, ok := element.(*proto.RPC); ok == true {
+				inputs.need(visitor.scope.ResolveConvertedTypeName(rpc.RequestType))
+			}
+		}
+	}
+
+	inputVisitor := visitor.forInputs(inputs)
+
+	// at least one pass is needed to find the empty input types
+	inputs.changed = true
+
+	// input types need the input types of their fields, and leave out fields of empty ones,
+	// so we repeat until no more are needed or found empty
+	for inputs.changed == true {
+		inputs.changed = false
+
+		for _, element := range def.Elements {
+			if message, ok := element.(*proto.Message); ok == true {
+				message.Accept(inputVisitor)
+
+				inputVisitor.Flush(ioutil.Discard)
+			}
+		}
+	}
+
+	for _, element := range def.Elements {
+		if message, ok := element.(*proto.Message); ok == true {
+			message.Accept(inputVisitor)
+
+			inputVisitor.Flush(t.out)
+		}
+	}
+}
+
 func (t *Transformer) resolveExternalPackages(packages []*ExternalPackage) error {
 	for _, pkg := range packages {
 		resp, err := http.Get(pkg.url)
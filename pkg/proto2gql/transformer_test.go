@@ -993,3 +993,681 @@ type MyAppGetUserResponse {
 		t.Fatalf("Expected %s to equal to %s", expected, actual)
 	}
 }
+
+func TestTransformMapFields(t *testing.T) {
+	schema := []byte(`
+syntax = "proto3";
+package my.app;
+
+message Secret {
+	string value = 1;
+}
+
+message Project {
+	message Member {
+		string name = 1;
+	}
+
+	map<string, int64> label_counts = 1;
+	map<int32, Member> members = 2;
+	map<string, Secret> secrets = 3;
+}
+`)
+
+	input := new(bytes.Buffer)
+	input.Write(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	transformer.SetFilter(func(typeName string) bool {
+		return typeName != "my.app.Secret"
+	})
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+type MyAppProject {
+    label_counts: [MyAppProjectLabelCountsEntry]
+    members: [MyAppProjectMembersEntry]
+}
+
+type MyAppProjectLabelCountsEntry {
+    key: String
+    value: Int
+}
+
+type MyAppProjectMembersEntry {
+    key: Int
+    value: MyAppProjectMember
+}
+
+type MyAppProjectMember {
+    name: String
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if expected != actual {
+		t.Fatalf("Expected %s to equal to %s", expected, actual)
+	}
+}
+
+func TestTransformOneofs(t *testing.T) {
+	schema := `
+syntax = "proto3";
+package my.app;
+
+enum Kind {
+	PERSON = 0;
+	ROBOT = 1;
+}
+
+message User {
+	string name = 1;
+}
+
+message Group {
+	string title = 1;
+}
+
+message Owner {
+	string id = 1;
+	oneof subject {
+		User user = 2;
+		Group group = 3;
+		Kind kind = 4;
+		string email = 5;
+	}
+}
+`
+
+	for _, each := range []struct {
+		strategy proto2gql.OneofStrategy
+		expected string
+	}{
+		{proto2gql.FlattenOneofs, `
+type MyAppOwner {
+    id: String
+    """Oneof subject: at most one of user, group, kind, email is set."""
+    user: MyAppUser
+    """Oneof subject: at most one of user, group, kind, email is set."""
+    group: MyAppGroup
+    """Oneof subject: at most one of user, group, kind, email is set."""
+    kind: MyAppKind
+    """Oneof subject: at most one of user, group, kind, email is set."""
+    email: String
+}
+`},
+		{proto2gql.UnionOneofs, `
+type MyAppOwner {
+    id: String
+    """Oneof subject: at most one of subject, kind, email is set."""
+    kind: MyAppKind
+    """Oneof subject: at most one of subject, kind, email is set."""
+    email: String
+    subject: MyAppOwnerSubject
+}
+
+union MyAppOwnerSubject = MyAppUser | MyAppGroup
+`},
+	} {
+		input := new(bytes.Buffer)
+		input.WriteString(schema)
+
+		output := new(bytes.Buffer)
+		transformer := proto2gql.NewTransformer(output)
+
+		transformer.SetOneofStrategy(each.strategy)
+
+		if err := transformer.Transform(input); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.TrimSpace(each.expected)
+		actual := strings.TrimSpace(output.String())
+
+		if strings.HasSuffix(actual, expected) == false {
+			t.Fatalf("Expected %s to end with %s", actual, expected)
+		}
+	}
+}
+
+func TestTransformOneofUnionWithLaterEnum(t *testing.T) {
+	schema := `
+syntax = "proto3";
+package my.app;
+
+message Owner {
+	oneof subject {
+		User user = 1;
+		Kind kind = 2;
+	}
+}
+
+message User {
+	string name = 1;
+}
+
+enum Kind {
+	PERSON = 0;
+}
+`
+
+	input := new(bytes.Buffer)
+	input.WriteString(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	transformer.SetOneofStrategy(proto2gql.UnionOneofs)
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+type MyAppOwner {
+    """Oneof subject: at most one of subject, kind is set."""
+    kind: MyAppKind
+    subject: MyAppOwnerSubject
+}
+
+union MyAppOwnerSubject = MyAppUser
+
+type MyAppUser {
+    name: String
+}
+
+enum MyAppKind {
+    PERSON
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if actual != expected {
+		t.Fatalf("Expected %s to be %s", actual, expected)
+	}
+}
+
+func TestTransformServices(t *testing.T) {
+	schema := []byte(`
+syntax = "proto3";
+package my.app;
+
+service UserService {
+	rpc GetUser(GetUserRequest) returns (User);
+	rpc ListUsers(ListUsersRequest) returns (ListUsersResponse);
+	rpc FindUser(GetUserRequest) returns (User) {
+		option idempotency_level = NO_SIDE_EFFECTS;
+	}
+	rpc GetOrCreateUser(GetUserRequest) returns (User) {
+		option idempotency_level = IDEMPOTENT;
+	}
+	rpc CreateUser(User) returns (User);
+	rpc WatchUsers(ListUsersRequest) returns (stream User);
+	rpc UploadUsers(stream User) returns (ListUsersResponse);
+}
+
+service HTTPService {
+	rpc HTTPStatus(GetUserRequest) returns (User);
+}
+
+message User {
+	string id = 1;
+}
+
+message GetUserRequest {
+	string id = 1;
+}
+
+message ListUsersRequest {
+}
+
+message ListUsersResponse {
+	repeated User users = 1;
+}
+`)
+
+	input := new(bytes.Buffer)
+	input.Write(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+type Query {
+    getUser(input: MyAppGetUserRequestInput!): MyAppUser
+    listUsers: MyAppListUsersResponse
+    findUser(input: MyAppGetUserRequestInput!): MyAppUser
+}
+
+type Mutation {
+    getOrCreateUser(input: MyAppGetUserRequestInput!): MyAppUser
+    createUser(input: MyAppUserInput!): MyAppUser
+}
+
+type Subscription {
+    watchUsers: MyAppUser
+}
+
+extend type Mutation {
+    httpStatus(input: MyAppGetUserRequestInput!): MyAppUser
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if strings.HasSuffix(actual, expected) == false {
+		t.Fatalf("Expected %s to end with %s", actual, expected)
+	}
+}
+
+func TestTransformInputTypes(t *testing.T) {
+	schema := `
+syntax = "proto3";
+package my.app;
+
+service Orders {
+	rpc CreateOrder(CreateOrderRequest) returns (Order);
+}
+
+enum Status {
+	OPEN = 0;
+	CLOSED = 1;
+}
+
+message Order {
+	message Line {
+		string sku = 1;
+		int32 quantity = 2;
+	}
+
+	string id = 1;
+	Status status = 2;
+	repeated Line lines = 3;
+	map<string, Line> lines_by_sku = 4;
+}
+
+message CreateOrderRequest {
+	Order order = 1;
+	oneof payment {
+		Card card = 2;
+		string voucher = 3;
+	}
+}
+
+message Card {
+	string number = 1;
+}
+
+message Unused {
+	string value = 1;
+}
+`
+
+	for _, each := range []struct {
+		strategy proto2gql.InputStrategy
+		expected string
+	}{
+		{proto2gql.RequestInputs, `
+input MyAppOrderInput {
+    id: String
+    status: MyAppStatus
+    lines: [MyAppOrderLineInput]
+    lines_by_sku: [MyAppOrderLinesBySkuEntryInput]
+}
+
+input MyAppOrderLinesBySkuEntryInput {
+    key: String
+    value: MyAppOrderLineInput
+}
+
+input MyAppOrderLineInput {
+    sku: String
+    quantity: Int
+}
+
+input MyAppCreateOrderRequestInput {
+    order: MyAppOrderInput
+    """Oneof payment: at most one of card, voucher is set."""
+    card: MyAppCardInput
+    """Oneof payment: at most one of card, voucher is set."""
+    voucher: String
+}
+
+input MyAppCardInput {
+    number: String
+}
+
+type Mutation {
+    createOrder(input: MyAppCreateOrderRequestInput!): MyAppOrder
+}
+`},
+		{proto2gql.AllInputs, `
+input MyAppCardInput {
+    number: String
+}
+
+input MyAppUnusedInput {
+    value: String
+}
+
+type Mutation {
+    createOrder(input: MyAppCreateOrderRequestInput!): MyAppOrder
+}
+`},
+	} {
+		input := new(bytes.Buffer)
+		input.WriteString(schema)
+
+		output := new(bytes.Buffer)
+		transformer := proto2gql.NewTransformer(output)
+
+		transformer.SetOneofStrategy(proto2gql.UnionOneofs)
+		transformer.SetInputStrategy(each.strategy)
+
+		if err := transformer.Transform(input); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.TrimSpace(each.expected)
+		actual := strings.TrimSpace(output.String())
+
+		if strings.HasSuffix(actual, expected) == false {
+			t.Fatalf("Expected %s to end with %s", actual, expected)
+		}
+
+		if strings.Count(actual, "enum MyAppStatus") != 1 {
+			t.Fatalf("Expected %s to have one enum MyAppStatus", actual)
+		}
+	}
+}
+
+func TestTransformComments(t *testing.T) {
+	schema := []byte(`
+syntax = "proto3";
+package my.app;
+
+service Notes {
+	// Returns a note by its id.
+	rpc GetNote(Note) returns (Note);
+}
+
+// The state of a note.
+enum State {
+	DRAFT = 0; // not yet published
+	// Visible to everyone.
+	PUBLISHED = 1;
+}
+
+/*
+ * A note, written in "markdown".
+ *
+ * Quotes: """
+ */
+message Note {
+	// The text, ending in a backslash \
+	string text = 1;
+	State state = 2; // see State
+	// Labels by name.
+	map<string, string> labels = 3;
+	oneof author {
+		// The name of a guest.
+		string guest = 4;
+	}
+}
+`)
+
+	input := new(bytes.Buffer)
+	input.Write(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+"""The state of a note."""
+enum MyAppState {
+    """not yet published"""
+    DRAFT
+    """Visible to everyone."""
+    PUBLISHED
+}
+
+"""
+A note, written in "markdown".
+
+Quotes: \"""
+"""
+type MyAppNote {
+    """
+    The text, ending in a backslash \
+    """
+    text: String
+    """see State"""
+    state: MyAppState
+    """Labels by name."""
+    labels: [MyAppNoteLabelsEntry]
+    """
+    The name of a guest.
+    Oneof author: at most one of guest is set.
+    """
+    guest: String
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if strings.HasPrefix(actual, expected) == false {
+		t.Fatalf("Expected %s to start with %s", actual, expected)
+	}
+
+	if strings.Contains(actual, `    """Returns a note by its id."""
+    getNote(input: MyAppNoteInput!): MyAppNote`) == false {
+		t.Fatalf("Expected %s to describe getNote", actual)
+	}
+}
+
+func TestTransformImportedRequestTypes(t *testing.T) {
+	schema := []byte(`
+syntax = "proto3";
+package my.app;
+
+import "google/protobuf/empty.proto";
+
+service Things {
+	rpc Ping(google.protobuf.Empty) returns (Thing);
+	rpc GetThing(GetThingRequest) returns (Thing);
+}
+
+message Thing {
+	string id = 1;
+}
+
+message GetThingRequest {
+	string id = 1;
+}
+`)
+
+	input := new(bytes.Buffer)
+	input.Write(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+input MyAppGetThingRequestInput {
+    id: String
+}
+
+type Query {
+    getThing(input: MyAppGetThingRequestInput!): MyAppThing
+}
+
+type Mutation {
+    ping: MyAppThing
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if strings.HasSuffix(actual, expected) == false {
+		t.Fatalf("Expected %s to end with %s", actual, expected)
+	}
+
+	if strings.Contains(actual, "EmptyInput") {
+		t.Fatalf("Expected %s to have no input type for the imported request", actual)
+	}
+}
+
+func TestTransformImportedFieldsOfInputTypes(t *testing.T) {
+	schema := []byte(`
+syntax = "proto3";
+package my.app;
+
+import "google/protobuf/timestamp.proto";
+import "other/thing.proto";
+
+service Things {
+	rpc CreateThing(CreateThingRequest) returns (Thing);
+}
+
+enum Kind {
+	SMALL = 0;
+}
+
+message Thing {
+	string id = 1;
+}
+
+message CreateThingRequest {
+	string name = 1;
+	Kind kind = 2;
+	Thing copy = 3;
+	google.protobuf.Timestamp at = 4;
+	other.Thing other = 5;
+	map<string, other.Thing> others = 6;
+}
+`)
+
+	input := new(bytes.Buffer)
+	input.Write(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+input MyAppThingInput {
+    id: String
+}
+
+input MyAppCreateThingRequestInput {
+    name: String
+    kind: MyAppKind
+    copy: MyAppThingInput
+}
+
+type Mutation {
+    createThing(input: MyAppCreateThingRequestInput!): MyAppThing
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if strings.HasSuffix(actual, expected) == false {
+		t.Fatalf("Expected %s to end with %s", actual, expected)
+	}
+}
+
+func TestTransformEmptyInputTypes(t *testing.T) {
+	schema := []byte(`
+syntax = "proto3";
+package my.app;
+
+import "google/protobuf/timestamp.proto";
+
+service Things {
+	rpc Ping(Empty) returns (Thing);
+	rpc Touch(TouchRequest) returns (Thing);
+	rpc GetThing(GetThingRequest) returns (Thing);
+}
+
+message TouchRequest {
+	Empty nothing = 1;
+	google.protobuf.Timestamp at = 2;
+}
+
+message GetThingRequest {
+	string id = 1;
+	TouchRequest touch = 2;
+}
+
+message Empty {}
+
+message Thing {
+	string id = 1;
+}
+`)
+
+	input := new(bytes.Buffer)
+	input.Write(schema)
+
+	output := new(bytes.Buffer)
+	transformer := proto2gql.NewTransformer(output)
+
+	if err := transformer.Transform(input); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+input MyAppGetThingRequestInput {
+    id: String
+}
+
+type Query {
+    getThing(input: MyAppGetThingRequestInput!): MyAppThing
+}
+
+type Mutation {
+    ping: MyAppThing
+    touch: MyAppThing
+}
+`
+
+	expected = strings.TrimSpace(expected)
+	actual := strings.TrimSpace(output.String())
+
+	if strings.HasSuffix(actual, expected) == false {
+		t.Fatalf("Expected %s to end with %s", actual, expected)
+	}
+
+	if strings.Contains(actual, "EmptyInput") || strings.Contains(actual, "TouchRequestInput") {
+		t.Fatalf("Expected %s to have no input types without fields", actual)
+	}
+}
@@ -29,14 +29,30 @@ type (
 	Visitor struct {
 		scope    *Scope
 		buff     *bytes.Buffer
+		types    *bytes.Buffer // types generated for fields, written after their message
 		children []*Visitor
 		filter   Filter
+		oneofs   OneofStrategy
+		queries  []string
+		roots    map[string]bool     // root operation types that are written already
+		fields   map[string][]string // fields of root operation types of a service, by type
+		input    *inputTypes         // not nil if the visitor writes input types
+		written  map[string]bool     // converted names of messages of which input types are written
+	}
+
+	// inputTypes holds the converted names of the messages of which input types are written.
+	inputTypes struct {
+		all     bool
+		needed  map[string]bool
+		empty   map[string]bool // messages of which input types would have no fields
+		changed bool            // true if a message is needed or found empty since it was reset
 	}
 )
 
 func NewVisitor(converter *Converter, filter Filter) *Visitor {
 	return &Visitor{
 		buff:     new(bytes.Buffer),
+		types:    new(bytes.Buffer),
 		children: make([]*Visitor, 0, 5),
 		scope:    NewScope(converter),
 		filter:   filter,
@@ -46,9 +62,13 @@ func NewVisitor(converter *Converter, filter Filter) *Visitor {
 func (v *Visitor) Fork(name string) *Visitor {
 	child := &Visitor{
 		buff:     new(bytes.Buffer),
+		types:    new(bytes.Buffer),
 		children: make([]*Visitor, 0, 5),
 		scope:    v.scope.Fork(name),
 		filter:   v.filter,
+		oneofs:   v.oneofs,
+		input:    v.input,
+		written:  v.written,
 	}
 
 	v.children = append(v.children, child)
@@ -56,6 +76,19 @@ func (v *Visitor) Fork(name string) *Visitor {
 	return child
 }
 
+// forInputs returns a visitor that writes the input types of messages, sharing the scope.
+func (v *Visitor) forInputs(inputs *inputTypes) *Visitor {
+	return &Visitor{
+		buff:     new(bytes.Buffer),
+		types:    new(bytes.Buffer),
+		children: make([]*Visitor, 0, 5),
+		scope:    v.scope,
+		filter:   v.filter,
+		input:    inputs,
+		written:  v.written,
+	}
+}
+
 func (v *Visitor) Flush(out io.Writer) {
 	out.Write(v.buff.Bytes())
 
@@ -74,22 +107,22 @@ func (v *Visitor) VisitMessage(m *proto.Message) {
 		return
 	}
 
-	v.buff.WriteString("\n")
+	typeName := v.scope.converter.NewTypeName(v.scope, m.Name)
 
-	v.buff.WriteString("type " + v.scope.converter.NewTypeName(v.scope, m.Name) + " {\n")
+	// an input type is written only if it is needed but its nested types may be needed anyway
+	written := v.input == nil || v.input.needs(typeName)
 
-	fields := make([]*proto.NormalField, 0, len(m.Elements))
+	fields := make([]proto.Visitee, 0, len(m.Elements))
 
 	for _, element := range m.Elements {
 
-		field, ok := element.(*proto.NormalField)
-
-		// it's not a nested message/enum
-		if ok == true {
+		switch element.(type) {
+		case *proto.NormalField, *proto.MapField, *proto.Oneof:
+			// it's not a nested message/enum
 			// we put it in array in order to process nested messages first
 			// in case they exist and have them in a scope
-			fields = append(fields, field)
-		} else {
+			fields = append(fields, element)
+		default:
 			// if so, create a nested visitor
 			// we need to track a parent's convertedName
 			// in order to generate a unique convertedName for nested ones
@@ -98,15 +131,80 @@ func (v *Visitor) VisitMessage(m *proto.Message) {
 		}
 	}
 
+	if written == false {
+		return
+	}
+
 	// now, having all nested messages in a scope, we can transform fields
+	buff := v.buff
+	v.buff = new(bytes.Buffer)
+
 	for _, field := range fields {
 		field.Accept(v)
 	}
 
+	body := v.buff
+	v.buff = buff
+
+	if v.input != nil {
+		// an input type without fields is invalid, so it is not written
+		if body.Len() == 0 {
+			v.input.setEmpty(typeName)
+			delete(v.written, typeName)
+
+			return
+		}
+
+		v.written[typeName] = true
+	}
+
+	v.buff.WriteString("\n")
+
+	v.buff.WriteString(description("", commentLines(m.Comment)))
+
+	v.buff.WriteString(v.keyword() + " " + v.typeName(typeName) + " {\n")
+
+	body.WriteTo(v.buff)
+
 	v.buff.WriteString("}\n")
 
+	// types generated for map fields and oneofs come right after the message
+	v.types.WriteTo(v.buff)
+}
+func (v *Visitor) VisitService(s *proto.Service) {
+	v.fields = make(map[string][]string)
+
+	for _, element := range s.Elements {
+		element.Accept(v)
+	}
+
+	for _, root := range []string{"Query", "Mutation", "Subscription"} {
+		fields, ok := v.fields[root]
+
+		if ok == false {
+			continue
+		}
+
+		v.buff.WriteString("\n")
+
+		// a root operation type can be written once, other services extend it
+		if v.roots[root] == true {
+			v.buff.WriteString("extend ")
+		}
+
+		v.roots[root] = true
+
+		v.buff.WriteString("type " + root + " {\n")
+
+		for _, field := range fields {
+			v.buff.WriteString(field + "\n")
+		}
+
+		v.buff.WriteString("}\n")
+	}
+
+	v.fields = nil
 }
-func (v *Visitor) VisitService(s *proto.Service) {}
 func (v *Visitor) VisitSyntax(s *proto.Syntax) {}
 func (v *Visitor) VisitPackage(p *proto.Package) {
 	v.scope.SetPackageName(p.Name)
@@ -120,9 +218,11 @@ func (v *Visitor) VisitNormalField(field *proto.NormalField) {
 		return
 	}
 
+	v.buff.WriteString(description("    ", fieldCommentLines(field.Comment, field.InlineComment, field.Parent)))
+
 	v.buff.WriteString("    " + field.Name + ":")
 
-	typeName := v.scope.ResolveConvertedTypeName(field.Type)
+	typeName := v.fieldTypeName(field.Type)
 
 	if field.Repeated == false {
 		v.buff.WriteString(" " + typeName)
@@ -137,18 +237,23 @@ func (v *Visitor) VisitNormalField(field *proto.NormalField) {
 	v.buff.WriteString("\n")
 }
 func (v *Visitor) VisitEnumField(i *proto.EnumField) {
+	v.buff.WriteString(description("    ", fieldCommentLines(i.Comment, i.InlineComment, i.Parent)))
+
 	v.buff.WriteString("    " + i.Name + "\n")
 }
 func (v *Visitor) VisitEnum(e *proto.Enum) {
 	// we add it to be able to resolve it in fields
-	v.scope.AddLocalType(e.Name)
+	v.scope.AddLocalEnum(e.Name)
 
-	if v.canTransformEnum(e) == false {
+	// enums are valid for input types too, so they are written once
+	if v.input != nil || v.canTransformEnum(e) == false {
 		return
 	}
 
 	v.buff.WriteString("\n")
 
+	v.buff.WriteString(description("", commentLines(e.Comment)))
+
 	v.buff.WriteString("enum " + v.scope.converter.NewTypeName(v.scope, e.Name) + " {\n")
 
 	for _, element := range e.Elements {
@@ -157,12 +262,119 @@ func (v *Visitor) VisitEnum(e *proto.Enum) {
 
 	v.buff.WriteString("}\n")
 }
-func (v *Visitor) VisitComment(e *proto.Comment)       {}
-func (v *Visitor) VisitOneof(o *proto.Oneof)           {}
-func (v *Visitor) VisitOneofField(o *proto.OneOfField) {}
-func (v *Visitor) VisitReserved(r *proto.Reserved)     {}
-func (v *Visitor) VisitRPC(r *proto.RPC)               {}
-func (v *Visitor) VisitMapField(f *proto.MapField)     {}
+
+// comments that document an element are its description, other comments are dropped
+func (v *Visitor) VisitComment(e *proto.Comment)   {}
+func (v *Visitor) VisitReserved(r *proto.Reserved) {}
+
+func (v *Visitor) VisitRPC(r *proto.RPC) {
+	// only RPCs of a service are transformed
+	if v.fields == nil {
+		return
+	}
+
+	// a stream of requests has no GraphQL equivalent
+	if r.StreamsRequest == true && r.StreamsReturns == false {
+		return
+	}
+
+	if v.canTransformFieldType(r.RequestType) == false || v.canTransformFieldType(r.ReturnsType) == false {
+		return
+	}
+
+	root := "Mutation"
+
+	switch {
+	case r.StreamsReturns == true:
+		root = "Subscription"
+	case v.isQuery(r):
+		root = "Query"
+	}
+
+	field := description("    ", commentLines(r.Comment)) + "    " + lowerCamelCase(r.Name)
+
+	// without an input type, such as for an imported request, the request cannot be an argument
+	if requestType := v.scope.ResolveConvertedTypeName(r.RequestType); v.written[requestType] == true {
+		field += "(input: " + requestType + "Input!)"
+	}
+
+	field += ": " + v.scope.ResolveConvertedTypeName(r.ReturnsType)
+
+	v.fields[root] = append(v.fields[root], field)
+}
+
+func (v *Visitor) VisitOneof(o *proto.Oneof) {
+	// input types cannot have fields of union types
+	if v.oneofs == FlattenOneofs || v.input != nil {
+		for _, element := range o.Elements {
+			element.Accept(v)
+		}
+
+		return
+	}
+
+	members := make([]string, 0, len(o.Elements))
+
+	for _, element := range o.Elements {
+		field, ok := element.(*proto.OneOfField)
+
+		if ok == true && v.isUnionMember(field) {
+			members = append(members, v.scope.ResolveConvertedTypeName(field.Type))
+		} else {
+			element.Accept(v)
+		}
+	}
+
+	if len(members) == 0 {
+		return
+	}
+
+	unionName := v.scope.converter.OneofTypeName(v.scope, parentMessageName(o.Parent), o.Name)
+
+	// the members of the union are listed by its type, so the field has only the comment of the oneof
+	v.buff.WriteString(description("    ", commentLines(o.Comment)))
+	v.buff.WriteString("    " + o.Name + ": " + unionName + "\n")
+
+	v.types.WriteString("\n")
+	v.types.WriteString(description("", commentLines(o.Comment)))
+	v.types.WriteString("union " + unionName + " = " + strings.Join(members, " | ") + "\n")
+}
+
+func (v *Visitor) VisitOneofField(o *proto.OneOfField) {
+	if v.canTransformFieldType(o.Type) == false {
+		return
+	}
+
+	lines := fieldCommentLines(o.Comment, o.InlineComment, o.Parent)
+
+	if oneof, ok := o.Parent.(*proto.Oneof); ok == true {
+		lines = append(lines, v.oneofDescription(oneof))
+	}
+
+	v.buff.WriteString(description("    ", lines))
+
+	// a member of a oneof is never set for sure, so it is nullable
+	v.buff.WriteString("    " + o.Name + ": " + v.fieldTypeName(o.Type) + "\n")
+}
+
+func (v *Visitor) VisitMapField(f *proto.MapField) {
+	if v.canTransformFieldType(f.KeyType) == false || v.canTransformFieldType(f.Type) == false {
+		return
+	}
+
+	// GraphQL has no maps, so a map is a list of generated key/value types
+	entryName := v.typeName(v.scope.converter.EntryTypeName(v.scope, parentMessageName(f.Parent), f.Name))
+
+	v.buff.WriteString(description("    ", fieldCommentLines(f.Comment, f.InlineComment, f.Parent)))
+
+	v.buff.WriteString("    " + f.Name + ": [" + entryName + "]\n")
+
+	v.types.WriteString("\n")
+	v.types.WriteString(v.keyword() + " " + entryName + " {\n")
+	v.types.WriteString("    key: " + v.scope.ResolveConvertedTypeName(f.KeyType) + "\n")
+	v.types.WriteString("    value: " + v.fieldTypeName(f.Type) + "\n")
+	v.types.WriteString("}\n")
+}
 
 // proto2
 func (v *Visitor) VisitGroup(g *proto.Group)           {}
@@ -173,20 +385,282 @@ func (v *Visitor) canTransformMessage(m *proto.Message) bool {
 }
 
 func (v *Visitor) canTransformMessageField(m *proto.NormalField) bool {
+	return v.canTransformFieldType(m.Type)
+}
+
+func (v *Visitor) canTransformFieldType(typeName string) bool {
 	// ignore builtins
-	_, builtin := BUILTINS[m.Type]
+	_, builtin := BUILTINS[typeName]
 
 	if builtin == true {
 		return true
 	}
 
-	if strings.Contains(m.Type, ".") {
-		return v.filter(m.Type)
+	// an input type can only refer to input types of this file, so a field of an imported message is left out
+	if v.input != nil && v.scope.IsLocal(typeName) == false {
+		return false
+	}
+
+	// and a field of a message of which the input type is not written too
+	if v.input != nil && v.input.empty[v.scope.ResolveConvertedTypeName(typeName)] == true {
+		return false
+	}
+
+	if strings.Contains(typeName, ".") {
+		return v.filter(typeName)
+	}
+
+	return v.filter(v.scope.ResolveFullTypeName(typeName))
+}
+
+// keyword returns the keyword of the types that are written.
+func (v *Visitor) keyword() string {
+	if v.input != nil {
+		return "input"
+	}
+
+	return "type"
+}
+
+// typeName returns the name of a type that is written, with the suffix Input for an input type.
+func (v *Visitor) typeName(convertedName string) string {
+	if v.input != nil {
+		return convertedName + "Input"
+	}
+
+	return convertedName
+}
+
+// fieldTypeName returns the converted name of the type of a field.
+// In an input type, a message type is its input type, which is then needed too.
+func (v *Visitor) fieldTypeName(ref string) string {
+	typeName := v.scope.ResolveConvertedTypeName(ref)
+
+	if v.input == nil {
+		return typeName
+	}
+
+	_, builtin := BUILTINS[ref]
+
+	if builtin == true || v.scope.IsEnum(ref) {
+		return typeName
+	}
+
+	v.input.need(typeName)
+
+	return typeName + "Input"
+}
+
+// isQuery returns true if an RPC has no side effects, by its option or by the prefix of its name.
+func (v *Visitor) isQuery(r *proto.RPC) bool {
+	for _, element := range r.Elements {
+		option, ok := element.(*proto.Option)
+
+		if ok == true && option.Name == "idempotency_level" {
+			return option.Constant.Source == "NO_SIDE_EFFECTS"
+		}
+	}
+
+	for _, prefix := range v.queries {
+		if strings.HasPrefix(r.Name, prefix) {
+			return true
+		}
 	}
 
-	return v.filter(v.scope.ResolveFullTypeName(m.Type))
+	return false
+}
+
+// isUnionMember returns true if a field of a oneof is converted into a member of its union.
+func (v *Visitor) isUnionMember(field *proto.OneOfField) bool {
+	if v.oneofs != UnionOneofs || v.input != nil || v.canTransformFieldType(field.Type) == false {
+		return false
+	}
+
+	// only object types can be members of a union
+	_, builtin := BUILTINS[field.Type]
+
+	return builtin == false && v.scope.IsEnum(field.Type) == false
+}
+
+// oneofDescription returns the description of the fields of a oneof, listing those that exclude each other.
+func (v *Visitor) oneofDescription(o *proto.Oneof) string {
+	names := make([]string, 0, len(o.Elements))
+	union := false
+
+	for _, element := range o.Elements {
+		field, ok := element.(*proto.OneOfField)
+
+		if ok == false || v.canTransformFieldType(field.Type) == false {
+			continue
+		}
+
+		if v.isUnionMember(field) {
+			if union == false {
+				names = append(names, o.Name)
+			}
+
+			union = true
+		} else {
+			names = append(names, field.Name)
+		}
+	}
+
+	return "Oneof " + o.Name + ": at most one of " + strings.Join(names, ", ") + " is set."
+}
+
+// parentMessageName returns the name of the message of a field, empty if it has none.
+func parentMessageName(parent proto.Visitee) string {
+	m, ok := parent.(*proto.Message)
+
+	if ok == false {
+		return ""
+	}
+
+	return m.Name
 }
 
 func (v *Visitor) canTransformEnum(e *proto.Enum) bool {
 	return v.filter(v.scope.converter.OriginalFullTypeName(v.scope, e.Name))
 }
+
+func newInputTypes(all bool) *inputTypes {
+	return &inputTypes{
+		all:    all,
+		needed: make(map[string]bool),
+		empty:  make(map[string]bool),
+	}
+}
+
+// needs returns true if the input type of a message is written.
+func (i *inputTypes) needs(convertedName string) bool {
+	return i.all || i.needed[convertedName]
+}
+
+// need marks the input type of a message as needed, remembering whether it was not needed before.
+func (i *inputTypes) need(convertedName string) {
+	if i.needed[convertedName] == false {
+		i.needed[convertedName] = true
+		i.changed = true
+	}
+}
+
+// setEmpty marks the input type of a message as having no fields, remembering whether it was not known before.
+func (i *inputTypes) setEmpty(convertedName string) {
+	if i.empty[convertedName] == false {
+		i.empty[convertedName] = true
+		i.changed = true
+	}
+}
+
+// description returns the lines of a description as an indented block string, empty if there are no lines.
+func description(indent string, lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	escaped := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		escaped = append(escaped, strings.Replace(line, `"""`, `\"""`, -1))
+	}
+
+	// a quote or backslash right before the closing quotes would change their meaning
+	if len(escaped) == 1 && strings.HasSuffix(escaped[0], `"`) == false && strings.HasSuffix(escaped[0], `\`) == false {
+		return indent + `"""` + escaped[0] + `"""` + "\n"
+	}
+
+	res := indent + `"""` + "\n"
+
+	for _, line := range escaped {
+		if line == "" {
+			res += "\n"
+		} else {
+			res += indent + line + "\n"
+		}
+	}
+
+	return res + indent + `"""` + "\n"
+}
+
+// fieldCommentLines returns the lines of the comment that documents a field or enum value,
+// which is the comment before it or else the comment after it on the same line.
+func fieldCommentLines(comment, inline *proto.Comment, parent proto.Visitee) []string {
+	// a comment after the opening brace of the parent is taken as the comment before its first element
+	if comment != nil && comment.Position.Line != openingLine(parent) {
+		if lines := commentLines(comment); len(lines) > 0 {
+			return lines
+		}
+	}
+
+	return commentLines(inline)
+}
+
+// openingLine returns the line of a message, enum or oneof, 0 if unknown.
+func openingLine(parent proto.Visitee) int {
+	switch parent := parent.(type) {
+	case *proto.Message:
+		return parent.Position.Line
+	case *proto.Enum:
+		return parent.Position.Line
+	case *proto.Oneof:
+		return parent.Position.Line
+	}
+
+	return 0
+}
+
+// commentLines returns the text of a comment without markers and common indentation, nil if it is empty.
+func commentLines(c *proto.Comment) []string {
+	if c == nil {
+		return nil
+	}
+
+	lines := make([]string, 0, len(c.Lines))
+	indent := -1
+
+	for _, line := range c.Lines {
+		line = strings.TrimRight(line, " \t\r")
+
+		if c.Cstyle == true {
+			// the leading asterisks of a /* */ comment are decoration
+			if trimmed := strings.TrimLeft(line, " \t"); strings.HasPrefix(trimmed, "*") {
+				line = strings.TrimPrefix(trimmed, "*")
+			}
+		}
+
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != "" {
+			if width := len(line) - len(trimmed); indent == -1 || width < indent {
+				indent = width
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	if indent == -1 {
+		return nil
+	}
+
+	for i, line := range lines {
+		if len(line) > indent {
+			lines[i] = line[indent:]
+		} else {
+			lines[i] = ""
+		}
+	}
+
+	// leading and trailing empty lines are not part of the text
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return lines
+}
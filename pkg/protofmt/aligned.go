@@ -40,7 +40,6 @@ var (
 	alignedComma     = leftAligned(", ")
 	alignedEmpty     = leftAligned("")
 	alignedSemicolon = notAligned(";")
-	alignedColon     = notAligned(":")
 )
 
 func leftAligned(src string) aligned  { return aligned{src, true, true} }
@@ -54,22 +53,71 @@ func (a aligned) preferredWidth() int {
 	return len(a.source)
 }
 
-func (a aligned) formatted(indentSeparator string, indentLevel, width int) string {
+func (a aligned) formatted(options FormatterOptions, indentLevel, width int) string {
 	if !a.padding {
+		if strings.IndexByte(a.source, '\n') == -1 {
+			return a.source
+		}
 		// if the source has newlines then make sure the correct indent level is applied
 		buf := new(bytes.Buffer)
 		for _, each := range a.source {
 			buf.WriteRune(each)
 			if each == '\n' {
-				buf.WriteString(strings.Repeat(indentSeparator, indentLevel))
+				buf.WriteString(strings.Repeat(options.indentSeparator(), indentLevel))
 			}
 		}
 		return buf.String()
 	}
+	if !options.AlignColumns {
+		return a.source
+	}
 	if a.left {
-		return a.source + strings.Repeat(" ", width-len(a.source))
+		return a.source + padding(width-len(a.source))
 	}
-	return strings.Repeat(" ", width-len(a.source)) + a.source
+	return padding(width-len(a.source)) + a.source
+}
+
+// spaces is used to pad columns without allocating the padding.
+const spaces = "                                                                "
+
+// padding returns a string of n spaces.
+func padding(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if n <= len(spaces) {
+		return spaces[:n]
+	}
+	return strings.Repeat(" ", n)
 }
 
 func (a aligned) hasAlignment() bool { return a.left || a.padding }
+
+// sourceOf returns the concatenated sources of the columns without padding.
+func sourceOf(cols []aligned) string {
+	buf := new(bytes.Buffer)
+	for _, each := range cols {
+		buf.WriteString(each.source)
+	}
+	return buf.String()
+}
+
+// lineWidth returns the length of the first line of the columns without padding.
+func lineWidth(cols []aligned) int {
+	width := 0
+	for _, each := range cols {
+		if i := strings.IndexByte(each.source, '\n'); i != -1 {
+			return width + i
+		}
+		width += len(each.source)
+	}
+	return width
+}
+
+// exceedsMaxLineWidth returns true if the columns, without padding, do not fit on an indented line.
+func exceedsMaxLineWidth(options FormatterOptions, indentLevel int, cols []aligned) bool {
+	if options.MaxLineWidth <= 0 {
+		return false
+	}
+	return indentLevel*len(options.indentSeparator())+lineWidth(cols) > options.MaxLineWidth
+}
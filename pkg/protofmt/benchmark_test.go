@@ -0,0 +1,50 @@
+package protofmt
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+// syntheticSource returns the source of a definition with a message of n fields and an enum of n values.
+func syntheticSource(n int) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, `syntax = "proto3";`)
+	fmt.Fprintln(buf, `package bench;`)
+	fmt.Fprintln(buf, `message Large {`)
+	for i := 1; i <= n; i++ {
+		if i%10 == 0 {
+			fmt.Fprintf(buf, "  // field %d\n", i)
+		}
+		if i%7 == 0 {
+			fmt.Fprintf(buf, "  repeated string field_%d = %d [deprecated = true]; // inline\n", i, i)
+		} else {
+			fmt.Fprintf(buf, "  int64 f%d = %d;\n", i, i)
+		}
+	}
+	fmt.Fprintln(buf, `}`)
+	fmt.Fprintln(buf, `enum Values {`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(buf, "  VALUE_%d = %d;\n", i, i)
+	}
+	fmt.Fprintln(buf, `}`)
+	return buf.Bytes()
+}
+
+// BenchmarkFormat formats definitions of increasing size; the throughput (MB/s) should not decrease with the size.
+func BenchmarkFormat(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		src := syntheticSource(n)
+		def, err := parse("large.proto", src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("fields=%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				NewFormatterWithOptions(ioutil.Discard, DefaultFormatterOptions()).Format(def)
+			}
+		})
+	}
+}
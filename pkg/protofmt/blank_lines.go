@@ -0,0 +1,146 @@
+package protofmt
+
+import (
+	"bytes"
+	"text/scanner"
+
+	"github.com/emicklei/proto"
+)
+
+// blankLinesBefore returns the elements, also nested ones, that have one or more empty lines before them in the source.
+// It uses the source order so that, when elements are reordered, the empty line moves with the element.
+// Without the source, no element has one.
+func blankLinesBefore(source []byte, elements []proto.Visitee) map[proto.Visitee]bool {
+	set := map[proto.Visitee]bool{}
+	var collect func(list []proto.Visitee)
+	collect = func(list []proto.Visitee) {
+		for i, each := range list {
+			if i > 0 && hadBlankLineBetween(source, list[i-1], each) {
+				set[each] = true
+			}
+			switch e := each.(type) {
+			case *proto.Message:
+				collect(e.Elements)
+			case *proto.Enum:
+				collect(e.Elements)
+			case *proto.Service:
+				collect(e.Elements)
+			case *proto.Oneof:
+				collect(e.Elements)
+			case *proto.Group:
+				collect(e.Elements)
+			}
+		}
+	}
+	collect(elements)
+	return set
+}
+
+// hadBlankLineBetween returns true if the source has one or more empty lines between two consecutive elements.
+// Lines are taken from the positions set by the parser; elements without a position never have one.
+func hadBlankLineBetween(source []byte, previous, next proto.Visitee) bool {
+	end, start := lastLine(source, previous), firstLine(next)
+	return end > 0 && start > 0 && start-end > 1
+}
+
+// firstLine returns the source line on which an element, including its documentation, starts.
+func firstLine(v proto.Visitee) int {
+	if hasDoc, ok := v.(proto.Documented); ok {
+		if doc := hasDoc.Doc(); doc != nil && doc.Position.Line > 0 {
+			return doc.Position.Line
+		}
+	}
+	return positionLine(v)
+}
+
+// lastLine returns the source line on which an element ends by scanning its source
+// up to the semicolon or closing brace that ends it; the parser does not keep the position of either.
+// It returns zero if the source or the position of the element is unknown.
+func lastLine(source []byte, v proto.Visitee) int {
+	if c, ok := v.(*proto.Comment); ok {
+		return c.Position.Line + len(c.Lines) - 1
+	}
+	pos := position(v)
+	if source == nil || pos.Line == 0 || pos.Offset >= len(source) {
+		return 0
+	}
+	var s scanner.Scanner
+	s.Init(bytes.NewReader(source[pos.Offset:]))
+	s.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanChars | scanner.ScanStrings |
+		scanner.ScanRawStrings | scanner.ScanComments | scanner.SkipComments
+	s.Error = func(*scanner.Scanner, string) {}
+	// the scanner starts counting lines at the position of the element
+	line := func() int { return pos.Line + s.Position.Line - 1 }
+	last, depth := pos.Line, 0
+	for tok := s.Scan(); tok != scanner.EOF; tok = s.Scan() {
+		last = line()
+		switch tok {
+		case '{', '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '}':
+			depth--
+			if depth == 0 {
+				// a body may be followed by a semicolon
+				if s.Scan() == ';' {
+					return line()
+				}
+				return last
+			}
+		case ';':
+			if depth == 0 {
+				return last
+			}
+		}
+	}
+	return last
+}
+
+// positionLine returns the line of the position of an element, zero if unknown.
+func positionLine(v proto.Visitee) int {
+	return position(v).Line
+}
+
+// position returns the position of an element as set by the parser.
+func position(v proto.Visitee) scanner.Position {
+	switch e := v.(type) {
+	case *proto.Comment:
+		return e.Position
+	case *proto.Edition:
+		return e.Position
+	case *proto.Syntax:
+		return e.Position
+	case *proto.Package:
+		return e.Position
+	case *proto.Import:
+		return e.Position
+	case *proto.Option:
+		return e.Position
+	case *proto.Message:
+		return e.Position
+	case *proto.Enum:
+		return e.Position
+	case *proto.EnumField:
+		return e.Position
+	case *proto.Service:
+		return e.Position
+	case *proto.RPC:
+		return e.Position
+	case *proto.NormalField:
+		return e.Position
+	case *proto.MapField:
+		return e.Position
+	case *proto.OneOfField:
+		return e.Position
+	case *proto.Oneof:
+		return e.Position
+	case *proto.Group:
+		return e.Position
+	case *proto.Reserved:
+		return e.Position
+	case *proto.Extensions:
+		return e.Position
+	}
+	return scanner.Position{}
+}
@@ -28,12 +28,13 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/emicklei/proto"
 )
 
-func columns(v proto.Visitee) []aligned {
-	return asColumnsPrintable(v).columns()
+func columns(v proto.Visitee, options FormatterOptions, indentLevel int) []aligned {
+	return asColumnsPrintable(v, options, indentLevel).columns()
 }
 
 // columnsPrintable is for elements that can be printed in aligned columns.
@@ -42,19 +43,36 @@ type columnsPrintable interface {
 }
 
 type columnsPrinter struct {
-	cols    []aligned
-	visitee proto.Visitee
+	cols        []aligned
+	visitee     proto.Visitee
+	options     FormatterOptions
+	indentLevel int  // of the line, needed to check the line width
+	wrapped     bool // true if written on multiple lines because of the line width
+	computed    bool // true if cols holds the columns of the visitee
 }
 
-func asColumnsPrintable(v proto.Visitee) *columnsPrinter {
+func asColumnsPrintable(v proto.Visitee, options FormatterOptions, indentLevel int) *columnsPrinter {
 	p := new(columnsPrinter)
 	p.visitee = v
+	p.options = options
+	p.indentLevel = indentLevel
 	return p
 }
 
-// columns is part of columnsPrintable
+// isWrapped returns true if the printable is written on multiple lines because of the line width.
+func isWrapped(c columnsPrintable) bool {
+	p, ok := c.(*columnsPrinter)
+	return ok && p.wrapped
+}
+
+// columns is part of columnsPrintable. The columns are computed once.
 func (p *columnsPrinter) columns() []aligned {
-	p.visitee.Accept(p)
+	if !p.computed {
+		// enough for a field with an option and an inline comment
+		p.cols = make([]aligned, 0, 16)
+		p.visitee.Accept(p)
+		p.computed = true
+	}
 	return p.cols
 }
 
@@ -73,7 +91,7 @@ func (p *columnsPrinter) VisitOption(o *proto.Option) {
 	} else {
 		p.cols = append(p.cols, leftAligned(" ["))
 	}
-	p.cols = append(p.cols, keyValuePair(o, o.IsEmbedded)...)
+	p.cols = append(p.cols, keyValuePair(o, o.IsEmbedded, p.options)...)
 	if o.IsEmbedded {
 		p.cols = append(p.cols, leftAligned("]"))
 	}
@@ -108,26 +126,52 @@ func (p *columnsPrinter) VisitNormalField(f *proto.NormalField) {
 		p.cols = append(p.cols, alignedEmpty)
 	}
 	p.cols = append(p.cols, leftAligned(f.Type), alignedSpace, leftAligned(f.Name), alignedEquals, rightAligned(strconv.Itoa(f.Sequence)))
-	if len(f.Options) > 0 {
-		p.cols = append(p.cols, leftAligned(" ["))
-		for i, each := range f.Options {
-			if i > 0 {
-				p.cols = append(p.cols, alignedComma)
-			}
-			p.cols = append(p.cols, keyValuePair(each, true)...)
-		}
-		p.cols = append(p.cols, leftAligned("]"))
-	}
-	p.cols = append(p.cols, alignedSemicolon)
+	p.appendFieldOptions(f.Options)
 	if f.InlineComment != nil {
 		p.cols = append(p.cols, alignedInlinePrefix(f.InlineComment), notAligned(f.InlineComment.Message()))
 	}
 }
 
+// appendFieldOptions adds the options, if any, and the statement end.
+// If the line becomes too wide then the options are written one per line.
+func (p *columnsPrinter) appendFieldOptions(options []*proto.Option) {
+	if len(options) == 0 {
+		p.cols = append(p.cols, alignedSemicolon)
+		return
+	}
+	start := len(p.cols)
+	p.cols = append(p.cols, leftAligned(" ["))
+	for i, each := range options {
+		if i > 0 {
+			p.cols = append(p.cols, alignedComma)
+		}
+		p.cols = append(p.cols, keyValuePair(each, true, p.options)...)
+	}
+	// not aligned because the number of options may differ per field
+	p.cols = append(p.cols, notAligned("]"), alignedSemicolon)
+	if !exceedsMaxLineWidth(p.options, p.indentLevel, p.cols) {
+		return
+	}
+	p.wrapped = true
+	wrapped := new(bytes.Buffer)
+	io.WriteString(wrapped, " [\n")
+	for i, each := range options {
+		// nested lines of an aggregate value need the extra indentation too
+		io.WriteString(wrapped, p.options.indentSeparator())
+		io.WriteString(wrapped, strings.Replace(sourceOf(keyValuePair(each, true, p.options)), "\n", "\n"+p.options.indentSeparator(), -1))
+		if i < len(options)-1 {
+			io.WriteString(wrapped, ",")
+		}
+		io.WriteString(wrapped, "\n")
+	}
+	io.WriteString(wrapped, "]")
+	p.cols = append(p.cols[:start], notAligned(wrapped.String()), alignedSemicolon)
+}
+
 func (p *columnsPrinter) VisitEnumField(f *proto.EnumField) {
 	p.cols = append(p.cols, leftAligned(f.Name), alignedEquals, rightAligned(strconv.Itoa(f.Integer)))
 	if f.ValueOption != nil {
-		p.cols = append(p.cols, columns(f.ValueOption)...)
+		p.cols = append(p.cols, columns(f.ValueOption, p.options, p.indentLevel)...)
 	}
 	p.cols = append(p.cols, alignedSemicolon)
 	if f.InlineComment != nil {
@@ -144,49 +188,36 @@ func (p *columnsPrinter) VisitOneofField(o *proto.OneOfField) {
 		leftAligned(o.Name),
 		alignedEquals,
 		rightAligned(strconv.Itoa(o.Sequence)))
-	if len(o.Options) > 0 {
-		p.cols = append(p.cols, leftAligned(" ["))
-		for i, each := range o.Options {
-			if i > 0 {
-				p.cols = append(p.cols, alignedComma)
-			}
-			p.cols = append(p.cols, keyValuePair(each, true)...)
-		}
-		p.cols = append(p.cols, leftAligned("]"))
-	}
-	p.cols = append(p.cols, alignedSemicolon)
+	p.appendFieldOptions(o.Options)
 	if o.InlineComment != nil {
 		p.cols = append(p.cols, notAligned(" //"), notAligned(o.InlineComment.Message()))
 	}
 }
-func (p *columnsPrinter) VisitReserved(rs *proto.Reserved) {}
-func (p *columnsPrinter) VisitRPC(r *proto.RPC) {
-	p.cols = append(p.cols,
-		leftAligned("rpc "),
-		leftAligned(r.Name),
-		leftAligned(" ("))
-	if r.StreamsRequest {
-		p.cols = append(p.cols, leftAligned("stream "))
-	} else {
-		p.cols = append(p.cols, alignedEmpty)
+func (p *columnsPrinter) VisitReserved(rs *proto.Reserved) {
+	list := []string{}
+	for _, each := range rs.Ranges {
+		list = append(list, each.SourceRepresentation())
 	}
-	p.cols = append(p.cols,
-		leftAligned(r.RequestType),
-		leftAligned(") "),
-		leftAligned("returns"),
-		leftAligned(" ("))
-	if r.StreamsReturns {
-		p.cols = append(p.cols, leftAligned("stream "))
-	} else {
-		p.cols = append(p.cols, alignedEmpty)
+	for _, each := range rs.FieldNames {
+		list = append(list, strconv.Quote(each))
+	}
+	p.cols = append(p.cols, leftAligned("reserved "), notAligned(strings.Join(list, ", ")), alignedSemicolon)
+	if rs.InlineComment != nil {
+		p.cols = append(p.cols, alignedInlinePrefix(rs.InlineComment), notAligned(rs.InlineComment.Message()))
+	}
+}
+func (p *columnsPrinter) VisitRPC(r *proto.RPC) {
+	p.appendSignature(r, false)
+	if exceedsMaxLineWidth(p.options, p.indentLevel, p.cols) {
+		// write the returns part on the next line
+		p.cols = p.cols[:0]
+		p.appendSignature(r, true)
+		p.wrapped = true
 	}
-	p.cols = append(p.cols,
-		leftAligned(r.ReturnsType),
-		leftAligned(")"))
 	if len(r.Elements) > 0 {
 		buf := new(bytes.Buffer)
 		io.WriteString(buf, " {\n")
-		f := NewFormatter(buf, "  ") // TODO get separator, now 2 spaces
+		f := NewFormatterWithOptions(buf, p.options)
 		f.level(1)
 		for _, each := range r.Elements {
 			each.Accept(f)
@@ -201,6 +232,41 @@ func (p *columnsPrinter) VisitRPC(r *proto.RPC) {
 		p.cols = append(p.cols, notAligned(" //"), notAligned(r.InlineComment.Message()))
 	}
 }
+
+// appendSignature adds the name, request and returns part of a RPC.
+// If wrapped then the returns part starts on a new line using a double indentation.
+func (p *columnsPrinter) appendSignature(r *proto.RPC, wrapped bool) {
+	p.cols = append(p.cols,
+		leftAligned("rpc "),
+		leftAligned(r.Name),
+		leftAligned(" ("))
+	if r.StreamsRequest {
+		p.cols = append(p.cols, leftAligned("stream "))
+	} else {
+		p.cols = append(p.cols, alignedEmpty)
+	}
+	if wrapped {
+		p.cols = append(p.cols,
+			leftAligned(r.RequestType),
+			notAligned(")"),
+			notAligned("\n"+strings.Repeat(p.options.indentSeparator(), 2)+"returns"))
+	} else {
+		p.cols = append(p.cols,
+			leftAligned(r.RequestType),
+			leftAligned(") "),
+			leftAligned("returns"))
+	}
+	p.cols = append(p.cols, leftAligned(" ("))
+	if r.StreamsReturns {
+		p.cols = append(p.cols, leftAligned("stream "))
+	} else {
+		p.cols = append(p.cols, alignedEmpty)
+	}
+	p.cols = append(p.cols,
+		leftAligned(r.ReturnsType),
+		leftAligned(")"))
+}
+
 func (p *columnsPrinter) VisitMapField(f *proto.MapField) {
 	p.cols = append(p.cols,
 		alignedEmpty, // no repeated no optional
@@ -209,20 +275,31 @@ func (p *columnsPrinter) VisitMapField(f *proto.MapField) {
 		leftAligned(f.Name),
 		alignedEquals,
 		rightAligned(strconv.Itoa(f.Sequence)))
-	if len(f.Options) > 0 {
-		p.cols = append(p.cols, leftAligned(" ["))
-		for i, each := range f.Options {
-			if i > 0 {
-				p.cols = append(p.cols, alignedComma)
-			}
-			p.cols = append(p.cols, keyValuePair(each, true)...)
-		}
-		p.cols = append(p.cols, leftAligned("]"))
-	}
-	p.cols = append(p.cols, alignedSemicolon)
+	p.appendFieldOptions(f.Options)
 	if f.InlineComment != nil {
 		p.cols = append(p.cols, alignedInlinePrefix(f.InlineComment), notAligned(f.InlineComment.Message()))
 	}
 }
-func (p *columnsPrinter) VisitGroup(g *proto.Group)           {}
-func (p *columnsPrinter) VisitExtensions(e *proto.Extensions) {}
+
+// VisitGroup is empty because a group has a body, which Formatter.VisitGroup writes like that of a message.
+func (p *columnsPrinter) VisitGroup(g *proto.Group) {}
+
+// VisitExtensions
+// [extensions][ranges][|options]
+func (p *columnsPrinter) VisitExtensions(e *proto.Extensions) {
+	list := []string{}
+	for _, each := range e.Ranges {
+		list = append(list, each.SourceRepresentation())
+	}
+	p.cols = append(p.cols, leftAligned("extensions "))
+	if len(e.Options) > 0 {
+		// align the options of consecutive extensions
+		p.cols = append(p.cols, leftAligned(strings.Join(list, ", ")))
+	} else {
+		p.cols = append(p.cols, notAligned(strings.Join(list, ", ")))
+	}
+	p.appendFieldOptions(e.Options)
+	if e.InlineComment != nil {
+		p.cols = append(p.cols, alignedInlinePrefix(e.InlineComment), notAligned(e.InlineComment.Message()))
+	}
+}
@@ -0,0 +1,152 @@
+package protofmt
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// formattedComment returns the lines of a comment written in the comment style of the options.
+// The text of line comments is normalized if the options say so; that of documentation is also reflowed.
+func (f *Formatter) formattedComment(c *proto.Comment, isDoc bool) []string {
+	lines := commentLines(c, f.options.CommentStyle)
+	if writtenAsBlock(c, f.options.CommentStyle) {
+		return lines
+	}
+	reflow := isDoc && f.options.CommentWidth > 0
+	if !reflow && !f.options.NormalizeComments {
+		return lines
+	}
+	marker := "//"
+	if c.ExtraSlash && !c.Cstyle {
+		marker = "///"
+	}
+	text := make([]string, len(lines))
+	for i, each := range lines {
+		text[i] = strings.TrimPrefix(each, marker)
+	}
+	text = normalizedCommentText(text)
+	if reflow {
+		text = reflowedCommentText(text, f.options.CommentWidth-f.indentLevel*len(f.indentSeparator)-len(marker))
+	}
+	for i, each := range text {
+		text[i] = marker + each
+	}
+	return text
+}
+
+// writtenAsBlock returns true if the comment is written as a /* */ block in a style.
+func writtenAsBlock(c *proto.Comment, style CommentStyle) bool {
+	switch style {
+	case CommentStyleLine:
+		return false
+	case CommentStyleBlock:
+		// the end marker in a line comment would end the block too early
+		// and a block cannot tell that it was written with an extra slash
+		return c.Cstyle || (!c.ExtraSlash && !containsBlockEnd(c))
+	}
+	return c.Cstyle
+}
+
+// containsBlockEnd returns true if a line of the comment contains the end marker of a block comment.
+func containsBlockEnd(c *proto.Comment) bool {
+	for _, each := range c.Lines {
+		if strings.Contains(each, "*/") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizedCommentText returns the text of comment lines, without markers, such that the least indented lines
+// start with a single space. Lines that are indented more keep their extra indentation.
+// Lines that start with a slash, such as those of a banner, are kept as is.
+func normalizedCommentText(lines []string) []string {
+	common := -1
+	for _, each := range lines {
+		if trimmed := strings.TrimLeft(each, " \t"); len(trimmed) > 0 && !strings.HasPrefix(each, "/") {
+			if n := len(each) - len(trimmed); common == -1 || n < common {
+				common = n
+			}
+		}
+	}
+	list := make([]string, len(lines))
+	for i, each := range lines {
+		switch {
+		case len(strings.TrimSpace(each)) == 0:
+			list[i] = ""
+		case strings.HasPrefix(each, "/"):
+			list[i] = each
+		case common > 1:
+			list[i] = each[common-1:]
+		case common == 0 && each[0] != ' ' && each[0] != '\t':
+			list[i] = " " + each
+		default:
+			list[i] = each
+		}
+	}
+	return list
+}
+
+// listItemPattern matches the marker of a list item: a dash, star, plus or a number followed by a dot or parenthesis.
+var listItemPattern = regexp.MustCompile(`^([-*+]|[0-9]{1,9}[.)]) +`)
+
+// reflowedCommentText returns normalized comment text in which the lines of each paragraph and list item are joined
+// and then wrapped such that lines are not longer than a width, unless a line has a single word.
+// Code, which is indented or fenced by ```, tables and headings are kept as is.
+func reflowedCommentText(lines []string, width int) (list []string) {
+	var words []string
+	first, hanging := "", ""
+	flush := func() {
+		if len(words) == 0 {
+			return
+		}
+		line := first + words[0]
+		for _, each := range words[1:] {
+			if len(line)+1+len(each) > width {
+				list = append(list, line)
+				line = hanging + each
+				continue
+			}
+			line += " " + each
+		}
+		list = append(list, line)
+		words = nil
+	}
+	fenced := false
+	for _, each := range lines {
+		trimmed := strings.TrimLeft(each, " \t")
+		indent := len(each) - len(trimmed)
+		marker := listItemPattern.FindString(trimmed)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			fenced = !fenced
+			list = append(list, each)
+		case fenced || len(trimmed) == 0 || strings.HasPrefix(each, "/") ||
+			strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "|"):
+			flush()
+			list = append(list, each)
+		case len(marker) > 0 && (indent == 1 || (len(words) > 0 && indent <= len(hanging))):
+			// a list item, possibly nested in the previous one
+			flush()
+			first = each[:indent] + marker
+			hanging = strings.Repeat(" ", len(first))
+			words = strings.Fields(trimmed[len(marker):])
+		case len(words) > 0 && indent == len(hanging):
+			// continues the paragraph or list item
+			words = append(words, strings.Fields(trimmed)...)
+		case indent > 1:
+			// code
+			flush()
+			list = append(list, each)
+		default:
+			flush()
+			first, hanging = each[:indent], each[:indent]
+			words = strings.Fields(trimmed)
+		}
+	}
+	flush()
+	return
+}
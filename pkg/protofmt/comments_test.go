@@ -0,0 +1,185 @@
+package protofmt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestFormatReflowComments(t *testing.T) {
+	src := `syntax = "proto3";
+//A request to search for
+//products.
+//Results are paged.
+//
+//Example:
+//    query: "shoes"
+//    page_size: 10
+//
+//- the query is matched against names and descriptions of products
+//- filters:
+//  1. by category
+//  2. by a price range that includes both the lower and the upper limit
+message SearchRequest {
+  /// The text to search for, which is matched against the names and descriptions.
+  string query = 1;
+  /* The maximum
+     number of results. */
+  int32 page_size = 2;
+}
+//not documentation,
+//kept as is
+`
+	expected := `syntax = "proto3";
+
+// A request to search for products. Results are
+// paged.
+//
+// Example:
+//    query: "shoes"
+//    page_size: 10
+//
+// - the query is matched against names and
+//   descriptions of products
+// - filters:
+//  1. by category
+//  2. by a price range that includes both the
+//     lower and the upper limit
+message SearchRequest {
+
+  /// The text to search for, which is matched
+  /// against the names and descriptions.
+  string query = 1;
+
+  // The maximum number of results.
+  int32 page_size = 2;
+}
+
+// not documentation,
+// kept as is
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.CommentStyle = CommentStyleLine
+	opts.CommentWidth = 50
+	opts.NormalizeComments = true
+	b := new(bytes.Buffer)
+	NewFormatterWithOptions(b, opts).Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+	if err := Verify("search.proto", []byte(src), opts); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNormalizedCommentText(t *testing.T) {
+	for _, each := range []struct {
+		lines, want []string
+	}{
+		{[]string{"a", " b", "", "   c"}, []string{" a", " b", "", "   c"}},
+		{[]string{"   a", "     b"}, []string{" a", "   b"}},
+		{[]string{"///////", "\ta"}, []string{"///////", "\ta"}},
+	} {
+		got := normalizedCommentText(each.lines)
+		if fmt.Sprint(got) != fmt.Sprint(each.want) {
+			t.Errorf("%q: got %q want %q", each.lines, got, each.want)
+		}
+	}
+}
+
+func TestFormatBlockStyleKeepsLineCommentWithBlockEnd(t *testing.T) {
+	src := `syntax = "proto2";
+message TestCommentInjectionMessage {
+  // */ <- This should not close the generated doc comment
+  optional string a = 1 [default="*/ <- Neither should this."];
+  // converted
+  optional string b = 2;
+}
+`
+	expected := `syntax = "proto2";
+
+message TestCommentInjectionMessage {
+
+  // */ <- This should not close the generated doc comment
+  optional string a = 1 [default = "*/ <- Neither should this."];
+
+  /*
+   * converted
+   */
+  optional string b = 2;
+}
+`
+	opts := DefaultFormatterOptions()
+	opts.CommentStyle = CommentStyleBlock
+	got, err := Source([]byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := expected; string(got) != want {
+		fmt.Println(diff(string(got), want))
+		fmt.Println(string(got))
+		t.Fail()
+	}
+	if err := Verify("unittest_proto2.proto", []byte(src), opts); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFormatCommentStyleKeepsExtraSlash(t *testing.T) {
+	src := `syntax = "proto3";
+/// The documentation
+/// of A.
+message A {
+  // the id
+  int32 id = 1;
+}
+`
+	block := `syntax = "proto3";
+
+/// The documentation
+/// of A.
+message A {
+
+  /*
+   * the id
+   */
+  int32 id = 1;
+}
+`
+	line := `syntax = "proto3";
+
+/// The documentation
+/// of A.
+message A {
+
+  // the id
+  int32 id = 1;
+}
+`
+	opts := DefaultFormatterOptions()
+	opts.CommentStyle = CommentStyleBlock
+	got, err := Source([]byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := block; string(got) != want {
+		fmt.Println(diff(string(got), want))
+		t.Fail()
+	}
+	// and back
+	opts.CommentStyle = CommentStyleLine
+	got, err = Source(got, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := line; string(got) != want {
+		fmt.Println(diff(string(got), want))
+		t.Fail()
+	}
+}
@@ -3,18 +3,16 @@ package protofmt
 import "github.com/emicklei/proto"
 
 // keyValuePair returns key = value or "value"
-func keyValuePair(o *proto.Option, embedded bool) (cols []aligned) {
+func keyValuePair(o *proto.Option, embedded bool, options FormatterOptions) (cols []aligned) {
 	equals := alignedEquals
 	name := o.Name
-	if len(o.Constant.OrderedMap) > 0 {
-		cols = append(cols, leftAligned(name), equals)
-		cols = append(cols, columnsPrintablesFromMap(o.Constant.OrderedMap)...)
-		return
+	if isAggregate(&o.Constant) {
+		return append(cols, leftAligned(name), equals, notAligned(literalSource(&o.Constant, options)))
 	}
 	if embedded {
-		return append(cols, leftAligned(name), equals, leftAligned(o.Constant.SourceRepresentation())) // numbers right, strings left? TODO
+		return append(cols, leftAligned(name), equals, leftAligned(scalarSource(&o.Constant, options))) // numbers right, strings left? TODO
 	}
-	return append(cols, rightAligned(name), equals, rightAligned(o.Constant.SourceRepresentation()))
+	return append(cols, rightAligned(name), equals, rightAligned(scalarSource(&o.Constant, options)))
 }
 
 func alignedInlinePrefix(c *proto.Comment) aligned {
@@ -25,26 +23,14 @@ func alignedInlinePrefix(c *proto.Comment) aligned {
 	return notAligned(prefix)
 }
 
-func columnsPrintables(c *proto.Comment) (list []columnsPrintable) {
-	for _, each := range c.Lines {
-		list = append(list, inlineComment{each, c.ExtraSlash})
+func columnsPrintables(lines []string) (list []columnsPrintable) {
+	for _, each := range lines {
+		list = append(list, commentLine(each))
 	}
 	return
 }
 
-func typeAssertColumnsPrintable(v proto.Visitee) (columnsPrintable, bool) {
-	return asColumnsPrintable(v), len(asColumnsPrintable(v).columns()) > 0
-}
-
-func columnsPrintablesFromMap(m proto.LiteralMap) (cols []aligned) {
-	cols = append(cols, leftAligned("{"), alignedSpace)
-	for i, each := range m {
-		// TODO only works for simple constants
-		if i > 0 {
-			cols = append(cols, alignedSpace)
-		}
-		cols = append(cols, leftAligned(each.Name), alignedColon, leftAligned(each.SourceRepresentation()))
-	}
-	cols = append(cols, alignedSpace, leftAligned("}"))
-	return
+func typeAssertColumnsPrintable(v proto.Visitee, options FormatterOptions, indentLevel int) (columnsPrintable, bool) {
+	p := asColumnsPrintable(v, options, indentLevel)
+	return p, len(p.columns()) > 0
 }
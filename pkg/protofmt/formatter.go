@@ -24,8 +24,11 @@
 package protofmt
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/emicklei/proto"
 )
@@ -33,46 +36,101 @@ import (
 // Formatter visits a Proto and writes formatted source.
 type Formatter struct {
 	w               io.Writer
+	options         FormatterOptions
 	indentSeparator string
 	indentLevel     int
 	lastStmt        string
 	lastLevel       int
+	// blankBefore holds the elements preceded by empty lines in the source, if these are preserved
+	blankBefore map[proto.Visitee]bool
+	// source of the definition, if known
+	source []byte
 }
 
-// NewFormatter returns a new Formatter. Only the indentation separator is configurable.
+// NewFormatter returns a new Formatter using the default options and the given indentation separator.
+// The separator must consist of either spaces or tabs.
 func NewFormatter(writer io.Writer, indentSeparator string) *Formatter {
-	return &Formatter{w: writer, indentSeparator: indentSeparator}
+	return NewFormatterWithOptions(writer, optionsWithIndentSeparator(indentSeparator))
+}
+
+// NewFormatterWithOptions returns a new Formatter that writes source according to the options.
+func NewFormatterWithOptions(writer io.Writer, options FormatterOptions) *Formatter {
+	return &Formatter{w: writer, options: options, indentSeparator: options.indentSeparator()}
+}
+
+// SetSource sets the source of the definition to format.
+// Empty lines can only be preserved with the source because the parser does not keep where bodies end.
+func (f *Formatter) SetSource(source []byte) {
+	f.source = source
 }
 
 // Format visits all proto elements and writes formatted source.
 func (f *Formatter) Format(p *proto.Proto) {
-	// check for Edition
-	if len(p.Elements) > 0 {
-		if e, ok := p.Elements[0].(*proto.Edition); ok {
-			f.begin("edition", e)
-			// inline doc is lost TODO
-			fmt.Fprintf(f.w, "edition = \"%s\";\n", e.Value)
-			f.end("edition")
-		}
+	defer f.buffered()()
+	elements := p.Elements
+	if f.options.PreserveBlankLines {
+		f.blankBefore = blankLinesBefore(f.source, elements)
+	}
+	if f.options.CanonicalOrder {
+		elements = canonicalOrder(elements)
 	}
-	for i, each := range p.Elements {
+	if f.options.SortImports {
+		elements = sortedImports(elements, f.options)
+	}
+	for i, each := range elements {
 		if i > 0 {
 			// group options and imports together
 			if _, ok1 := each.(*proto.Option); ok1 && f.lastStmt == "option" {
-				// no newline
-			} else if _, ok1 := each.(*proto.Import); ok1 && f.lastStmt == "import" {
-				// no newline
+				// no newline unless the source has one
+				f.preservedBlankLine(each)
+			} else if imp, ok1 := each.(*proto.Import); ok1 && f.lastStmt == "import" {
+				// no newline unless it starts a new group of sorted imports or the source has one
+				if prev, ok2 := elements[i-1].(*proto.Import); ok2 && f.options.SortImports &&
+					f.options.importGroup(prev) != f.options.importGroup(imp) {
+					f.nl()
+				} else {
+					f.preservedBlankLine(each)
+				}
 			} else {
-				f.nl()
+				f.blankLines()
 			}
 		}
-		each.Accept(f)
+		if e, ok := each.(*proto.Edition); ok {
+			// an Edition does not dispatch to a Visitor (yet)
+			f.visitEdition(e)
+		} else {
+			each.Accept(f)
+		}
 	}
 }
 
+// buffered makes the formatter write through a buffer, unless its writer is one.
+// It returns the function that flushes the buffer and restores the writer.
+func (f *Formatter) buffered() func() {
+	switch f.w.(type) {
+	case *bytes.Buffer, *bufio.Writer, *strings.Builder:
+		return func() {}
+	}
+	w := f.w
+	buf := bufio.NewWriter(w)
+	f.w = buf
+	return func() {
+		buf.Flush()
+		f.w = w
+	}
+}
+
+// visitEdition formats an Edition.
+func (f *Formatter) visitEdition(e *proto.Edition) {
+	f.begin("edition", e)
+	fmt.Fprintf(f.w, "edition = %q", e.Value)
+	f.endWithComment(e.InlineComment)
+	f.end("edition")
+}
+
 // VisitComment formats a Comment and writes enclosing newlines.
 func (f *Formatter) VisitComment(c *proto.Comment) {
-	f.printComment(c)
+	f.printComment(c, false)
 }
 
 // VisitEnum formats a Enum.
@@ -96,8 +154,9 @@ func (f *Formatter) VisitEnumField(e *proto.EnumField) {
 
 // VisitImport formats a Import.
 func (f *Formatter) VisitImport(i *proto.Import) {
-	f.begin("import", i)
-	f.printAsGroups([]proto.Visitee{i})
+	f.lastStmt = "import"
+	f.printDoc(i)
+	f.printListOfColumns([]columnsPrintable{asColumnsPrintable(i, f.options, f.indentLevel)})
 	f.end("import")
 }
 
@@ -113,7 +172,7 @@ func (f *Formatter) VisitMessage(m *proto.Message) {
 	if len(m.Elements) > 0 {
 		f.nl()
 		f.level(1)
-		f.printAsGroups(m.Elements)
+		f.printAsGroups(f.messageElements(m.Elements))
 		f.indent(-1)
 	}
 	io.WriteString(f.w, "}\n")
@@ -133,48 +192,9 @@ func (f *Formatter) VisitOption(o *proto.Option) {
 	f.end("option")
 }
 
+// formatLiteral writes a Literal with nested maps and arrays indented from the current level.
 func (f *Formatter) formatLiteral(l *proto.Literal) {
-	if len(l.OrderedMap) == 0 && len(l.Array) == 0 && len(l.Source) == 0 {
-		if l.IsString {
-			fmt.Fprintf(f.w, "\"\"")
-		} else {
-			fmt.Fprintf(f.w, "{}")
-		}
-		return
-	}
-	if len(l.OrderedMap) == 0 && len(l.Array) == 0 {
-		fmt.Fprintf(f.w, "%s", l.SourceRepresentation())
-		return
-	}
-	if len(l.OrderedMap) > 0 {
-		fmt.Fprintf(f.w, "{\n")
-		for _, other := range l.OrderedMap {
-			f.indent(1)
-			fmt.Fprintf(f.w, "%s", other.Name)
-			if other.PrintsColon {
-				fmt.Fprintf(f.w, ": ")
-			}
-			f.formatLiteral(other.Literal)
-			f.level(-1)
-			f.nl()
-		}
-		f.indent(0)
-		fmt.Fprintf(f.w, "}")
-	}
-	if len(l.Array) > 0 {
-		fmt.Fprintf(f.w, "[\n")
-		for i, other := range l.Array {
-			f.indent(1)
-			f.formatLiteral(other)
-			if i < len(l.Array)-1 {
-				fmt.Fprintf(f.w, ",")
-			}
-			f.level(-1)
-			f.nl()
-		}
-		f.indent(0)
-		fmt.Fprintf(f.w, "]")
-	}
+	io.WriteString(f.w, notAligned(literalSource(l, f.options)).formatted(f.options, f.indentLevel, 0))
 }
 
 // VisitPackage formats a Package.
@@ -214,13 +234,21 @@ func (f *Formatter) VisitOneof(o *proto.Oneof) {
 	if len(o.Elements) > 0 {
 		f.nl()
 		f.level(1)
-		f.printAsGroups(o.Elements)
+		f.printAsGroups(f.messageElements(o.Elements))
 		f.indent(-1)
 	}
 	io.WriteString(f.w, "}\n")
 	f.end("oneof")
 }
 
+// messageElements returns the elements of a message or oneof in the order they are written.
+func (f *Formatter) messageElements(elements []proto.Visitee) []proto.Visitee {
+	if f.options.SortMessageElements {
+		return messageOrder(elements)
+	}
+	return elements
+}
+
 // VisitOneofField formats a OneofField.
 func (f *Formatter) VisitOneofField(o *proto.OneOfField) {
 	f.printAsGroups([]proto.Visitee{o})
@@ -228,24 +256,7 @@ func (f *Formatter) VisitOneofField(o *proto.OneOfField) {
 
 // VisitReserved formats a Reserved.
 func (f *Formatter) VisitReserved(r *proto.Reserved) {
-	f.begin("reserved", r)
-	io.WriteString(f.w, "reserved ")
-	if len(r.Ranges) > 0 {
-		for i, each := range r.Ranges {
-			if i > 0 {
-				io.WriteString(f.w, ", ")
-			}
-			fmt.Fprintf(f.w, "%s", each.SourceRepresentation())
-		}
-	} else {
-		for i, each := range r.FieldNames {
-			if i > 0 {
-				io.WriteString(f.w, ", ")
-			}
-			fmt.Fprintf(f.w, "%q", each)
-		}
-	}
-	f.endWithComment(r.InlineComment)
+	f.printAsGroups([]proto.Visitee{r})
 }
 
 // VisitRPC formats a RPC.
@@ -266,7 +277,12 @@ func (f *Formatter) VisitNormalField(f1 *proto.NormalField) {
 // VisitGroup formats a proto2 Group.
 func (f *Formatter) VisitGroup(g *proto.Group) {
 	f.begin("group", g)
-	if g.Optional {
+	switch {
+	case g.Repeated:
+		io.WriteString(f.w, "repeated ")
+	case g.Required:
+		io.WriteString(f.w, "required ")
+	case g.Optional:
 		io.WriteString(f.w, "optional ")
 	}
 	fmt.Fprintf(f.w, "group %s = %d {", g.Name, g.Sequence)
@@ -282,13 +298,5 @@ func (f *Formatter) VisitGroup(g *proto.Group) {
 
 // VisitExtensions formats a proto2 Extensions.
 func (f *Formatter) VisitExtensions(e *proto.Extensions) {
-	f.begin("extensions", e)
-	io.WriteString(f.w, "extensions ")
-	for i, each := range e.Ranges {
-		if i > 0 {
-			io.WriteString(f.w, ", ")
-		}
-		fmt.Fprintf(f.w, "%s", each.SourceRepresentation())
-	}
-	f.endWithComment(e.InlineComment)
+	f.printAsGroups([]proto.Visitee{e})
 }
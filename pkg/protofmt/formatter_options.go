@@ -0,0 +1,86 @@
+package protofmt
+
+import "strings"
+
+// CommentStyle tells how comments are written.
+type CommentStyle int
+
+const (
+	// CommentStylePreserve writes comments using the style found in the source.
+	CommentStylePreserve CommentStyle = iota
+	// CommentStyleLine writes all comments using // prefixed lines.
+	CommentStyleLine
+	// CommentStyleBlock writes all comments as /* ... */ blocks.
+	CommentStyleBlock
+)
+
+// FormatterOptions holds the settings that determine the layout of formatted source.
+// Use DefaultFormatterOptions to start from the standard protofmt style.
+type FormatterOptions struct {
+	// IndentWidth is the number of spaces (or tabs if UseTabs) per indentation level.
+	IndentWidth int
+	// UseTabs indents using tab characters instead of spaces.
+	UseTabs bool
+	// AlignColumns pads the parts of consecutive fields, enum values, rpcs and options to align them.
+	AlignColumns bool
+	// BlankLinesBetweenDeclarations is the number of empty lines written between top-level declarations.
+	BlankLinesBetweenDeclarations int
+	// MaxLineWidth is the preferred maximum length of a line. Zero means no limit.
+	MaxLineWidth int
+	// CommentStyle tells whether comments are converted to line or block style.
+	CommentStyle CommentStyle
+	// CommentWidth is the maximum length of the lines of // documentation, of which the paragraphs and
+	// list items are reflowed. Code, which is indented or fenced by ```, is kept as is. Zero means no reflow.
+	CommentWidth int
+	// NormalizeComments writes a single space between the // and the text of comment lines.
+	// Lines that are indented more than the others keep their extra indentation.
+	NormalizeComments bool
+	// NormalizeLiterals writes string literals between double quotes with the same escaping everywhere
+	// and, if DecimalNumbers, numbers in decimal notation. Identifiers, such as enum constants and booleans,
+	// are written as in the source.
+	NormalizeLiterals bool
+	// DecimalNumbers writes hexadecimal and octal integers, and floats with an exponent, as decimals
+	// if NormalizeLiterals. Otherwise numbers are written as in the source.
+	DecimalNumbers bool
+	// SortImports sorts imports by filename, removes duplicates and writes them in groups:
+	// well-known (google/protobuf), third-party, local, public and weak imports.
+	SortImports bool
+	// LocalImportPrefixes are the filename prefixes of imports that belong to the local group.
+	LocalImportPrefixes []string
+	// CanonicalOrder writes the top-level elements in the order: edition or syntax, package, imports,
+	// options, services, messages, enums and extends. Elements of the same kind keep their order.
+	CanonicalOrder bool
+	// SortMessageElements writes the elements of messages and oneofs in the order: options,
+	// nested types, fields by number, reserved and extensions.
+	SortMessageElements bool
+	// PreserveBlankLines keeps a single empty line between the elements of a body (and between
+	// consecutive imports or options) wherever the source has one or more. No other empty lines are added there.
+	// A Formatter needs the source for this, see SetSource.
+	PreserveBlankLines bool
+}
+
+// DefaultFormatterOptions returns the options used by the protofmt command.
+func DefaultFormatterOptions() FormatterOptions {
+	return FormatterOptions{
+		IndentWidth:                   2,
+		AlignColumns:                  true,
+		BlankLinesBetweenDeclarations: 1,
+		CommentStyle:                  CommentStylePreserve,
+	}
+}
+
+// optionsWithIndentSeparator returns the default options with the indentation derived from a separator string.
+func optionsWithIndentSeparator(indentSeparator string) FormatterOptions {
+	o := DefaultFormatterOptions()
+	o.IndentWidth = len(indentSeparator)
+	o.UseTabs = len(indentSeparator) > 0 && strings.Trim(indentSeparator, "\t") == ""
+	return o
+}
+
+// indentSeparator returns the string written for one level of indentation.
+func (o FormatterOptions) indentSeparator() string {
+	if o.UseTabs {
+		return strings.Repeat("\t", o.IndentWidth)
+	}
+	return strings.Repeat(" ", o.IndentWidth)
+}
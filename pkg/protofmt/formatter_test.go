@@ -55,7 +55,7 @@ func TestPrintListOfColumns(t *testing.T) {
 	op1.Constant = proto.Literal{Source: "1234"}
 	e1.ValueOption = op1
 
-	list := []columnsPrintable{asColumnsPrintable(e0), asColumnsPrintable(e1)}
+	list := []columnsPrintable{asColumnsPrintable(e0, DefaultFormatterOptions(), 0), asColumnsPrintable(e1, DefaultFormatterOptions(), 0)}
 	b := new(bytes.Buffer)
 	f := NewFormatter(b, " ")
 	f.printListOfColumns(list)
@@ -395,3 +395,404 @@ service S2 {}
 		t.Fail()
 	}
 }
+
+func TestFormatterOptions(t *testing.T) {
+	src := `/* about */
+syntax = "proto3";
+message A {
+  // the id
+  int32 id = 1;
+  string name = 2;
+}
+service S {
+  rpc Get (A) returns (A) {
+    option deprecated = true;
+  }
+}`
+	expected := `// about
+syntax = "proto3";
+
+
+message A {
+
+	// the id
+	int32 id = 1;
+	string name = 2;
+}
+
+
+service S {
+	rpc Get (A) returns (A) {
+		option deprecated = true;
+	}
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.UseTabs = true
+	opts.IndentWidth = 1
+	opts.AlignColumns = false
+	opts.BlankLinesBetweenDeclarations = 2
+	opts.CommentStyle = CommentStyleLine
+	b := new(bytes.Buffer)
+	NewFormatterWithOptions(b, opts).Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		t.Fail()
+	}
+}
+
+func TestFormatSortedImports(t *testing.T) {
+	src := `syntax = "proto3";
+import "z/other.proto";
+import public "a/public.proto";
+// timestamp
+import "google/protobuf/timestamp.proto";
+package test;
+import "acme/api/v1/types.proto"; // types
+import "google/api/annotations.proto";
+import "z/other.proto";
+import weak "b/weak.proto";
+import "google/protobuf/any.proto";
+import "acme/api/v1/enums.proto";
+message A {}
+`
+	expected := `syntax = "proto3";
+
+import "google/protobuf/any.proto";
+// timestamp
+import "google/protobuf/timestamp.proto";
+
+import "google/api/annotations.proto";
+import "z/other.proto";
+
+import "acme/api/v1/enums.proto";
+import "acme/api/v1/types.proto"; // types
+
+import public "a/public.proto";
+
+import weak "b/weak.proto";
+
+package test;
+
+message A {}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.SortImports = true
+	opts.LocalImportPrefixes = []string{"acme/"}
+	b := new(bytes.Buffer)
+	NewFormatterWithOptions(b, opts).Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+}
+
+func TestFormatCanonicalOrder(t *testing.T) {
+	src := `// header
+enum E {}
+// about M
+message M {
+  string name = 2;
+  // nested
+  enum Kind {}
+  option deprecated = true;
+  oneof choice {
+    int32 b = 5;
+    int32 a = 3;
+  }
+  int32 id = 1;
+}
+extend M {}
+service S {}
+import "a.proto";
+option go_package = "x";
+package test;
+syntax = "proto3";
+// trailing
+`
+	expected := `syntax = "proto3";
+
+package test;
+
+import "a.proto";
+
+option go_package = "x";
+
+service S {}
+
+// about M
+message M {
+  option deprecated = true;
+  // nested
+  enum Kind {}
+  int32  id   = 1;
+  string name = 2;
+  oneof choice {
+    int32 a = 3;
+    int32 b = 5;
+  }
+}
+
+// header
+enum E {}
+
+extend M {}
+
+// trailing
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.CanonicalOrder = true
+	opts.SortMessageElements = true
+	b := new(bytes.Buffer)
+	NewFormatterWithOptions(b, opts).Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+}
+
+func TestFormatFieldOptionsOfDifferentLength(t *testing.T) {
+	src := `message A {
+  string a = 1 [deprecated = true, json_name = "first"];
+  int32 bb = 2 [deprecated = true];
+}
+`
+	expected := `message A {
+  string a  = 1 [deprecated = true, json_name = "first"];
+  int32  bb = 2 [deprecated = true];
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	NewFormatter(b, "  ").Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+}
+
+func TestFormatMaxLineWidth(t *testing.T) {
+	src := `message A {
+  string id = 1;
+  string name = 2 [(validate.rules).string.min_len = 1, (validate.rules).string.max_len = 64, deprecated = true];
+  int32 a_very_long_field_name_that_does_not_fit_on_the_line_at_all_when_aligned = 3;
+  int64 count = 4;
+}
+service S {
+  rpc Get (GetRequest) returns (GetResponse);
+  rpc ListAllTheThingsInTheWorld (ListAllTheThingsInTheWorldRequest) returns (stream ListAllTheThingsInTheWorldResponse);
+}
+`
+	expected := `message A {
+  string id    = 1;
+  string name = 2 [
+    (validate.rules).string.min_len = 1,
+    (validate.rules).string.max_len = 64,
+    deprecated = true
+  ];
+  int32 a_very_long_field_name_that_does_not_fit_on_the_line_at_all_when_aligned = 3;
+  int64  count = 4;
+}
+
+service S {
+  rpc Get (GetRequest) returns (GetResponse);
+  rpc ListAllTheThingsInTheWorld (ListAllTheThingsInTheWorldRequest)
+      returns (stream ListAllTheThingsInTheWorldResponse);
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.MaxLineWidth = 80
+	b := new(bytes.Buffer)
+	NewFormatterWithOptions(b, opts).Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+}
+
+func TestFormatAggregateFieldOptions(t *testing.T) {
+	src := `message A {
+  option (my.message) = { name: "a" tags: ["x", "y"] };
+  Item item = 1 [(validate.rules).message = {required: true, items: {min_len: 1 nested {flag: true}}}];
+  int32 id = 2 [(x) = {}];
+}
+enum E {
+  V = 0 [(my.value) = {labels: [{key: "k" value: "v"}]}];
+}
+`
+	expected := `message A {
+  option (my.message) = {
+    name: "a"
+    tags: [
+      "x",
+      "y"
+    ]
+  };
+  Item  item = 1 [(validate.rules).message = {
+    required: true
+    items: {
+      min_len: 1
+      nested {
+        flag: true
+      }
+    }
+  }];
+  int32 id   = 2 [(x)                      = {}];
+}
+
+enum E {
+  V = 0 [(my.value) = {
+    labels: [
+      {
+        key: "k"
+        value: "v"
+      }
+    ]
+  }];
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := formatted(def), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+}
+
+func TestFormatPreserveBlankLines(t *testing.T) {
+	src := `syntax = "proto3";
+import "a.proto";
+
+
+import "b.proto";
+message A {
+  // identity
+  int32 id = 1;
+  string name = 2;
+
+
+
+  // audit
+  int64 created_at = 3;
+  int64 updated_at = 4 [(x) = {
+    a: 1
+  }];
+
+  message B { int32 b = 1; }
+  reserved 9;
+  message C {
+    int32 c = 1;
+
+  }
+  int32 after_c = 5;
+}
+`
+	expected := `syntax = "proto3";
+
+import "a.proto";
+
+import "b.proto";
+
+message A {
+  // identity
+  int32  id   = 1;
+  string name = 2;
+
+  // audit
+  int64 created_at = 3;
+  int64 updated_at = 4 [(x) = {
+    a: 1
+  }];
+
+  message B {
+    int32 b = 1;
+  }
+  reserved 9;
+  message C {
+    int32 c = 1;
+  }
+  int32 after_c = 5;
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.PreserveBlankLines = true
+	b := new(bytes.Buffer)
+	f := NewFormatterWithOptions(b, opts)
+	f.SetSource([]byte(src))
+	f.Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+}
+
+func TestFormatProto2GroupsReservedExtensions(t *testing.T) {
+	src := `syntax = "proto2";
+message A {
+  required group Required = 1 { optional int32 a = 2; }
+  repeated group Repeated = 3 {}
+  reserved 4, 9 to 11; // old
+  reserved "b";
+  extensions 100 to 199 [verification = UNVERIFIED];
+  extensions 1000 to max [verification = DECLARATION, (x) = 1];
+  extensions 20;
+}
+`
+	expected := `syntax = "proto2";
+
+message A {
+  required group Required = 1 {
+    optional int32 a = 2;
+  }
+  repeated group Repeated = 3 {}
+  reserved 4, 9 to 11; // old
+  reserved "b";
+  extensions 100 to 199  [verification = UNVERIFIED ];
+  extensions 1000 to max [verification = DECLARATION, (x) = 1];
+  extensions 20;
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := formatted(def), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+	if err := Verify("a.proto", []byte(src), DefaultFormatterOptions()); err != nil {
+		t.Error(err)
+	}
+}
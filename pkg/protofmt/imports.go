@@ -0,0 +1,96 @@
+package protofmt
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// import groups in the order they are written when sorting imports.
+const (
+	wellKnownImports = iota
+	thirdPartyImports
+	localImports
+	publicImports
+	weakImports
+)
+
+// importGroup returns the group in which an import is written when sorting imports.
+func (o FormatterOptions) importGroup(i *proto.Import) int {
+	switch {
+	case i.Kind == "public":
+		return publicImports
+	case i.Kind == "weak":
+		return weakImports
+	case strings.HasPrefix(i.Filename, "google/protobuf/"):
+		return wellKnownImports
+	}
+	for _, each := range o.LocalImportPrefixes {
+		if strings.HasPrefix(i.Filename, each) {
+			return localImports
+		}
+	}
+	return thirdPartyImports
+}
+
+// sortedImports returns the elements with all imports sorted, grouped and without duplicates.
+// The imports are placed at the position of the first import; other elements keep their order.
+func sortedImports(elements []proto.Visitee, options FormatterOptions) []proto.Visitee {
+	imports := []*proto.Import{}
+	first := -1
+	for i, each := range elements {
+		if imp, ok := each.(*proto.Import); ok {
+			if first == -1 {
+				first = i
+			}
+			imports = append(imports, imp)
+		}
+	}
+	if first == -1 {
+		return elements
+	}
+	imports = uniqueImports(imports)
+	sort.SliceStable(imports, func(i, j int) bool {
+		gi, gj := options.importGroup(imports[i]), options.importGroup(imports[j])
+		if gi != gj {
+			return gi < gj
+		}
+		return imports[i].Filename < imports[j].Filename
+	})
+	sorted := make([]proto.Visitee, 0, len(elements))
+	for i, each := range elements {
+		if i == first {
+			for _, other := range imports {
+				sorted = append(sorted, other)
+			}
+		}
+		if _, ok := each.(*proto.Import); !ok {
+			sorted = append(sorted, each)
+		}
+	}
+	return sorted
+}
+
+// uniqueImports removes imports with the same filename and kind as an earlier one.
+// The comments of a removed import are kept if the earlier one has none.
+// Returned imports are copies so the comments of the originals are not changed.
+func uniqueImports(imports []*proto.Import) (list []*proto.Import) {
+	seen := map[string]*proto.Import{}
+	for _, each := range imports {
+		key := each.Kind + " " + each.Filename
+		if earlier, ok := seen[key]; ok {
+			if earlier.Comment == nil {
+				earlier.Comment = each.Comment
+			}
+			if earlier.InlineComment == nil {
+				earlier.InlineComment = each.InlineComment
+			}
+			continue
+		}
+		copied := *each
+		seen[key] = &copied
+		list = append(list, &copied)
+	}
+	return
+}
@@ -15,3 +15,10 @@ func (i inlineComment) columns() (list []aligned) {
 	}
 	return append(list, notAligned(prefix+i.line))
 }
+
+// commentLine is a line of a comment that already includes its comment markers.
+type commentLine string
+
+func (c commentLine) columns() (list []aligned) {
+	return append(list, notAligned(string(c)))
+}
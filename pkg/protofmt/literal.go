@@ -0,0 +1,227 @@
+package protofmt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emicklei/proto"
+)
+
+// isAggregate returns true if the literal is a map or array, possibly empty.
+func isAggregate(l *proto.Literal) bool {
+	return len(l.OrderedMap) > 0 || l.Array != nil || (len(l.Source) == 0 && !l.IsString)
+}
+
+// literalSource returns the source of a Literal. Entries of maps and arrays are written on separate lines,
+// indented relative to the line on which the literal starts.
+func literalSource(l *proto.Literal, options FormatterOptions) string {
+	buf := new(bytes.Buffer)
+	writeLiteral(buf, l, options, 0)
+	return buf.String()
+}
+
+func writeLiteral(buf *bytes.Buffer, l *proto.Literal, options FormatterOptions, depth int) {
+	if len(l.OrderedMap) == 0 && len(l.Array) == 0 && len(l.Source) == 0 {
+		if l.IsString {
+			buf.WriteString(`""`)
+		} else if l.Array != nil {
+			buf.WriteString("[]")
+		} else {
+			buf.WriteString("{}")
+		}
+		return
+	}
+	if len(l.OrderedMap) == 0 && len(l.Array) == 0 {
+		buf.WriteString(scalarSource(l, options))
+		return
+	}
+	indentSeparator := options.indentSeparator()
+	inner := strings.Repeat(indentSeparator, depth+1)
+	if len(l.OrderedMap) > 0 {
+		buf.WriteString("{\n")
+		for _, other := range l.OrderedMap {
+			buf.WriteString(inner)
+			buf.WriteString(other.Name)
+			if other.PrintsColon {
+				buf.WriteString(": ")
+			} else {
+				buf.WriteString(" ")
+			}
+			writeLiteral(buf, other.Literal, options, depth+1)
+			buf.WriteString("\n")
+		}
+		buf.WriteString(strings.Repeat(indentSeparator, depth))
+		buf.WriteString("}")
+	}
+	if len(l.Array) > 0 {
+		buf.WriteString("[\n")
+		for i, other := range l.Array {
+			buf.WriteString(inner)
+			writeLiteral(buf, other, options, depth+1)
+			if i < len(l.Array)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(strings.Repeat(indentSeparator, depth))
+		buf.WriteString("]")
+	}
+}
+
+// scalarSource returns the source of a string, number or identifier, normalized if the options say so.
+// Identifiers, such as enum constants and booleans, are written as in the source.
+func scalarSource(l *proto.Literal, options FormatterOptions) string {
+	if !options.NormalizeLiterals {
+		return l.SourceRepresentation()
+	}
+	if l.IsString {
+		if value, ok := unescaped(l.Source); ok {
+			return quoted(value)
+		}
+		return l.SourceRepresentation()
+	}
+	if options.DecimalNumbers {
+		if decimal, ok := decimalNumber(l.Source); ok {
+			return decimal
+		}
+	}
+	return l.SourceRepresentation()
+}
+
+// decimalNumber returns a hexadecimal or octal integer, or a float with an exponent, in decimal notation.
+// Other numbers and identifiers are not changed.
+func decimalNumber(source string) (string, bool) {
+	digits := strings.TrimLeft(source, "+-")
+	if len(digits) == 0 || digits[0] < '0' || digits[0] > '9' {
+		return source, false
+	}
+	lower := strings.ToLower(digits)
+	switch {
+	case strings.HasPrefix(lower, "0x") || (len(digits) > 1 && digits[0] == '0' && !strings.ContainsAny(lower, ".e")):
+		if i, err := strconv.ParseInt(source, 0, 64); err == nil {
+			return strconv.FormatInt(i, 10), true
+		}
+		if u, err := strconv.ParseUint(source, 0, 64); err == nil {
+			return strconv.FormatUint(u, 10), true
+		}
+	case strings.Contains(lower, "e"):
+		if f, err := strconv.ParseFloat(source, 64); err == nil {
+			// very large or small values are more readable with an exponent
+			if decimal := strconv.FormatFloat(f, 'f', -1, 64); len(decimal) <= 24 {
+				return decimal, true
+			}
+		}
+	}
+	return source, false
+}
+
+// unescaped returns the value of the source of a string literal, false if it has an unknown escape sequence.
+func unescaped(source string) (string, bool) {
+	if strings.IndexByte(source, '\\') == -1 {
+		return source, true
+	}
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		if c != '\\' {
+			buf.WriteByte(c)
+			continue
+		}
+		if i++; i == len(source) {
+			return source, false
+		}
+		switch c = source[i]; c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			buf.WriteByte(c)
+		case 'x', 'X':
+			// one or two hexadecimal digits
+			n := 0
+			for n < 2 && i+1+n < len(source) && strings.IndexByte("0123456789abcdefABCDEF", source[i+1+n]) != -1 {
+				n++
+			}
+			if n == 0 {
+				return source, false
+			}
+			v, _ := strconv.ParseUint(source[i+1:i+1+n], 16, 8)
+			buf.WriteByte(byte(v))
+			i += n
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(source) {
+				return source, false
+			}
+			v, err := strconv.ParseUint(source[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(v)) {
+				return source, false
+			}
+			buf.WriteRune(rune(v))
+			i += n
+		default:
+			// one to three octal digits
+			n := 0
+			for n < 3 && i+n < len(source) && source[i+n] >= '0' && source[i+n] <= '7' {
+				n++
+			}
+			if n == 0 {
+				return source, false
+			}
+			v, err := strconv.ParseUint(source[i:i+n], 8, 8)
+			if err != nil {
+				return source, false
+			}
+			buf.WriteByte(byte(v))
+			i += n - 1
+		}
+	}
+	return buf.String(), true
+}
+
+// quoted returns a string literal between double quotes. Quotes, backslashes, control characters
+// and bytes that are not valid UTF-8 are escaped.
+func quoted(value string) string {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('"')
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(buf, "\\x%02x", value[i])
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(buf, "\\x%02x", r)
+		default:
+			buf.WriteRune(r)
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
@@ -0,0 +1,386 @@
+package protofmt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/scanner"
+	"unicode"
+
+	"github.com/emicklei/proto"
+)
+
+// NameCollision describes a rename that would give an element the name of another one.
+type NameCollision struct {
+	Filename string
+	Line     int
+	Old      string
+	New      string
+	// Other is the (original) name of the element that has or gets the same name.
+	Other string
+}
+
+// NameCollisionError lists all renames of FixNames that collide.
+type NameCollisionError struct {
+	Collisions []NameCollision
+}
+
+func (e *NameCollisionError) Error() string {
+	lines := []string{}
+	for _, each := range e.Collisions {
+		lines = append(lines, fmt.Sprintf("%s:%d: cannot rename %q to %q, it collides with %q", each.Filename, each.Line, each.Old, each.New, each.Other))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FixNames renames message and enum types to PascalCase, fields to lower_snake_case and
+// enum values to UPPER_SNAKE_CASE prefixed by the name of their enum.
+// All references in the definitions are rewritten: field types, rpc request and response types and extend targets.
+// The definitions are handled as one set so references between them are rewritten too.
+// A field gets a json_name option if renaming it would change its JSON name.
+// Groups and the fields of extend blocks keep their names.
+// If any rename collides with another name then nothing is changed and a *NameCollisionError is returned.
+func FixNames(definitions ...*proto.Proto) error {
+	r := &renamer{types: map[string]string{}, values: map[string]map[string]string{}, namespaces: map[string]map[string][]declaredName{}}
+	for _, each := range definitions {
+		r.filename = each.Filename
+		r.collect(each.Elements, packageOf(each))
+	}
+	if collisions := r.collisions(); len(collisions) > 0 {
+		return &NameCollisionError{Collisions: collisions}
+	}
+	for _, each := range definitions {
+		r.rewrite(each.Elements, packageOf(each), packageOf(each))
+	}
+	return nil
+}
+
+// declaredName is a name declared in a namespace, before and after renaming.
+type declaredName struct {
+	filename string
+	line     int
+	old, new string
+}
+
+// renamer holds the new names of all declarations, by full name.
+type renamer struct {
+	filename string
+	// types maps the full name of a message or enum to its new full name
+	types map[string]string
+	// values maps the full name of an enum to the new names of its values
+	values map[string]map[string]string
+	// namespaces holds the declared names by namespace, then by new name
+	namespaces map[string]map[string][]declaredName
+}
+
+// declare adds a name to a namespace.
+func (r *renamer) declare(namespace string, pos scanner.Position, old, new string) {
+	names, ok := r.namespaces[namespace]
+	if !ok {
+		names = map[string][]declaredName{}
+		r.namespaces[namespace] = names
+	}
+	names[new] = append(names[new], declaredName{filename: r.filename, line: pos.Line, old: old, new: new})
+}
+
+// collect computes the new names of the declarations in a scope.
+func (r *renamer) collect(elements []proto.Visitee, scope string) {
+	for _, each := range elements {
+		switch e := each.(type) {
+		case *proto.Message:
+			if e.IsExtend {
+				continue
+			}
+			full := qualified(scope, e.Name)
+			r.types[full] = r.newTypeName(scope, pascalCase(e.Name))
+			r.declare("type "+scope, e.Position, e.Name, pascalCase(e.Name))
+			r.collectFields(e.Elements, full)
+			r.collect(e.Elements, full)
+		case *proto.Group:
+			full := qualified(scope, e.Name)
+			r.types[full] = r.newTypeName(scope, e.Name)
+			r.declare("type "+scope, e.Position, e.Name, e.Name)
+			r.collectFields(e.Elements, full)
+			r.collect(e.Elements, full)
+		case *proto.Enum:
+			full := qualified(scope, e.Name)
+			r.types[full] = r.newTypeName(scope, pascalCase(e.Name))
+			r.declare("type "+scope, e.Position, e.Name, pascalCase(e.Name))
+			// enum values are in the scope of their enum, not in the enum itself
+			prefix := upperSnakeCase(pascalCase(e.Name)) + "_"
+			values := map[string]string{}
+			for _, other := range e.Elements {
+				if v, ok := other.(*proto.EnumField); ok {
+					values[v.Name] = enumValueName(prefix, v.Name)
+					r.declare("value "+scope, v.Position, v.Name, values[v.Name])
+				}
+			}
+			r.values[full] = values
+		case *proto.Oneof:
+			r.collect(e.Elements, scope)
+		}
+	}
+}
+
+// collectFields computes the new names of the fields of a message, including those in oneofs.
+func (r *renamer) collectFields(elements []proto.Visitee, message string) {
+	for _, each := range elements {
+		switch e := each.(type) {
+		case *proto.NormalField:
+			r.declare("field "+message, e.Position, e.Name, lowerSnakeCase(e.Name))
+		case *proto.MapField:
+			r.declare("field "+message, e.Position, e.Name, lowerSnakeCase(e.Name))
+		case *proto.Group:
+			r.declare("field "+message, e.Position, e.Name, strings.ToLower(e.Name))
+		case *proto.Oneof:
+			r.collectFields(e.Elements, message)
+		case *proto.OneOfField:
+			r.declare("field "+message, e.Position, e.Name, lowerSnakeCase(e.Name))
+		}
+	}
+}
+
+// newTypeName returns the new full name of a type declared in a scope.
+func (r *renamer) newTypeName(scope, name string) string {
+	if newScope, ok := r.types[scope]; ok {
+		return qualified(newScope, name)
+	}
+	// the scope is a package
+	return qualified(scope, name)
+}
+
+// collisions returns, sorted by position, the renamed names that are equal to another name in their namespace.
+func (r *renamer) collisions() []NameCollision {
+	list := []NameCollision{}
+	for _, names := range r.namespaces {
+		for _, declared := range names {
+			for _, each := range declared {
+				if each.old == each.new {
+					continue
+				}
+				for _, other := range declared {
+					if other.old != each.old {
+						list = append(list, NameCollision{Filename: each.filename, Line: each.line, Old: each.old, New: each.new, Other: other.old})
+						break
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Filename != list[j].Filename {
+			return list[i].Filename < list[j].Filename
+		}
+		if list[i].Line != list[j].Line {
+			return list[i].Line < list[j].Line
+		}
+		return list[i].Old < list[j].Old
+	})
+	return list
+}
+
+// rewrite renames the declarations in a scope and rewrites their references.
+// The lookup scope is where references are resolved; this is the package for services and extend blocks.
+func (r *renamer) rewrite(elements []proto.Visitee, scope, lookup string) {
+	for _, each := range elements {
+		switch e := each.(type) {
+		case *proto.Message:
+			if e.IsExtend {
+				e.Name = r.reference(lookup, e.Name)
+				r.rewrite(e.Elements, scope, lookup)
+				continue
+			}
+			full := qualified(scope, e.Name)
+			e.Name = pascalCase(e.Name)
+			r.rewrite(e.Elements, full, full)
+		case *proto.Group:
+			full := qualified(scope, e.Name)
+			r.rewrite(e.Elements, full, full)
+		case *proto.Enum:
+			values := r.values[qualified(scope, e.Name)]
+			e.Name = pascalCase(e.Name)
+			for _, other := range e.Elements {
+				if v, ok := other.(*proto.EnumField); ok {
+					v.Name = values[v.Name]
+				}
+			}
+		case *proto.Oneof:
+			r.rewrite(e.Elements, scope, lookup)
+		case *proto.NormalField:
+			r.rewriteField(e.Field, lookup, e.Parent)
+		case *proto.MapField:
+			r.rewriteField(e.Field, lookup, e.Parent)
+		case *proto.OneOfField:
+			r.rewriteField(e.Field, lookup, e.Parent)
+		case *proto.Service:
+			r.rewrite(e.Elements, scope, lookup)
+		case *proto.RPC:
+			e.RequestType = r.reference(lookup, e.RequestType)
+			e.ReturnsType = r.reference(lookup, e.ReturnsType)
+		}
+	}
+}
+
+// rewriteField renames a field, unless it is an extension, and rewrites its type and default value.
+func (r *renamer) rewriteField(f *proto.Field, lookup string, parent proto.Visitee) {
+	if full, ok := r.resolve(lookup, f.Type); ok {
+		for _, each := range f.Options {
+			if values, isEnum := r.values[full]; isEnum && each.Name == "default" && !each.Constant.IsString {
+				if value, ok := values[each.Constant.Source]; ok {
+					each.Constant.Source = value
+				}
+			}
+		}
+		f.Type = rewritten(f.Type, r.types[full])
+	}
+	if m, ok := parent.(*proto.Message); ok && m.IsExtend {
+		return
+	}
+	name := lowerSnakeCase(f.Name)
+	if name == f.Name {
+		return
+	}
+	if jsonName(name) != jsonName(f.Name) && !hasOption(f.Options, "json_name") {
+		f.Options = append(f.Options, &proto.Option{
+			Position: f.Position,
+			Name:     "json_name",
+			Constant: proto.Literal{Source: jsonName(f.Name), IsString: true, QuoteRune: '"'},
+			Parent:   parent,
+		})
+	}
+	f.Name = name
+}
+
+// reference returns a type reference rewritten to the new name of the type, if it is known.
+func (r *renamer) reference(lookup, ref string) string {
+	if full, ok := r.resolve(lookup, ref); ok {
+		return rewritten(ref, r.types[full])
+	}
+	return ref
+}
+
+// resolve returns the full name of the type that a reference refers to, looking in the scope and its parent scopes.
+func (r *renamer) resolve(scope, ref string) (string, bool) {
+	if strings.HasPrefix(ref, ".") {
+		_, ok := r.types[ref[1:]]
+		return ref[1:], ok
+	}
+	for {
+		if _, ok := r.types[qualified(scope, ref)]; ok {
+			return qualified(scope, ref), true
+		}
+		if len(scope) == 0 {
+			return "", false
+		}
+		if dot := strings.LastIndex(scope, "."); dot != -1 {
+			scope = scope[:dot]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// rewritten returns the reference with as many trailing parts of the new full name as it has parts.
+func rewritten(ref, full string) string {
+	if strings.HasPrefix(ref, ".") {
+		return "." + full
+	}
+	parts := strings.Split(full, ".")
+	return strings.Join(parts[len(parts)-strings.Count(ref, ".")-1:], ".")
+}
+
+func qualified(scope, name string) string {
+	if len(scope) == 0 {
+		return name
+	}
+	return scope + "." + name
+}
+
+// packageOf returns the package name of a definition, empty if it has none.
+func packageOf(def *proto.Proto) string {
+	for _, each := range def.Elements {
+		if p, ok := each.(*proto.Package); ok {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func hasOption(options []*proto.Option, name string) bool {
+	for _, each := range options {
+		if each.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enumValueName returns the value name in UPPER_SNAKE_CASE that starts with the prefix.
+func enumValueName(prefix, name string) string {
+	upper := upperSnakeCase(name)
+	if strings.HasPrefix(upper, prefix) {
+		return upper
+	}
+	return prefix + upper
+}
+
+// jsonName returns the JSON name that protoc derives from a field name.
+func jsonName(name string) string {
+	b := new(strings.Builder)
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// pascalCase returns the name with the first letter of each underscore separated part in upper case, without underscores.
+func pascalCase(name string) string {
+	b := new(strings.Builder)
+	upper := true
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return name
+	}
+	return b.String()
+}
+
+// lowerSnakeCase returns the name with its words, separated by underscores or changes in case, in lower case joined by underscores.
+func lowerSnakeCase(name string) string {
+	runes := []rune(name)
+	b := new(strings.Builder)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// upperSnakeCase returns the name in lower snake case with all letters in upper case.
+func upperSnakeCase(name string) string {
+	return strings.ToUpper(lowerSnakeCase(name))
+}
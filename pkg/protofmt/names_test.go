@@ -0,0 +1,139 @@
+package protofmt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFixNames(t *testing.T) {
+	a, err := parse("a.proto", []byte(`syntax = "proto2";
+package shop;
+message order_line {
+  optional int32 productID = 1;
+  optional line_state state = 2 [default = open];
+  enum line_state {
+    open = 0;
+    LINE_STATE_CLOSED = 1;
+  }
+  extensions 100 to 199;
+}
+extend order_line {
+  optional string giftNote = 100;
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parse("b.proto", []byte(`syntax = "proto2";
+package shop;
+import "a.proto";
+message Basket {
+  repeated order_line lines = 1;
+  map<string, .shop.order_line> byName = 2 [json_name = "names"];
+  optional order_line.line_state worst = 3;
+}
+service Baskets {
+  rpc Add(order_line) returns (Basket);
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := FixNames(a, b); err != nil {
+		t.Fatal(err)
+	}
+	for _, each := range []struct {
+		got, want string
+	}{
+		{formatted(a), `syntax = "proto2";
+
+package shop;
+
+message OrderLine {
+  optional int32     product_id = 1 [json_name = "productID"    ];
+  optional LineState state      = 2 [default   = LINE_STATE_OPEN];
+  enum LineState {
+    LINE_STATE_OPEN   = 0;
+    LINE_STATE_CLOSED = 1;
+  }
+  extensions 100 to 199;
+}
+
+extend OrderLine {
+  optional string giftNote = 100;
+}
+`},
+		{formatted(b), `syntax = "proto2";
+
+package shop;
+
+import "a.proto";
+
+message Basket {
+  repeated OrderLine                    lines   = 1;
+           map <string,.shop.OrderLine> by_name = 2 [json_name = "names"];
+  optional OrderLine.LineState          worst   = 3;
+}
+
+service Baskets {
+  rpc Add (OrderLine) returns (Basket);
+}
+`},
+	} {
+		if each.got != each.want {
+			fmt.Println(diff(each.got, each.want))
+			t.Fail()
+		}
+	}
+}
+
+func TestFixNamesReportsCollisions(t *testing.T) {
+	def, err := parse("a.proto", []byte(`message A {
+  string userName = 1;
+  string user_name = 2;
+  message b {}
+  message B {}
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = FixNames(def)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if got, want := err.Error(), `a.proto:2: cannot rename "userName" to "user_name", it collides with "user_name"
+a.proto:4: cannot rename "b" to "B", it collides with "B"`; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	// nothing is renamed
+	if got, want := formatted(def), `message A {
+  string userName  = 1;
+  string user_name = 2;
+  message b {}
+  message B {}
+}
+`; got != want {
+		fmt.Println(diff(got, want))
+		t.Fail()
+	}
+}
+
+func TestNameCases(t *testing.T) {
+	for _, each := range []struct {
+		name, pascal, snake string
+	}{
+		{"order_line", "OrderLine", "order_line"},
+		{"productID", "ProductID", "product_id"},
+		{"HTTPServer", "HTTPServer", "http_server"},
+		{"field2Name", "Field2Name", "field2_name"},
+		{"already_snake_1", "AlreadySnake1", "already_snake_1"},
+	} {
+		if got := pascalCase(each.name); got != each.pascal {
+			t.Errorf("%s: got %q want %q", each.name, got, each.pascal)
+		}
+		if got := lowerSnakeCase(each.name); got != each.snake {
+			t.Errorf("%s: got %q want %q", each.name, got, each.snake)
+		}
+	}
+}
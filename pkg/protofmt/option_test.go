@@ -1,6 +1,7 @@
 package protofmt
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -55,3 +56,80 @@ func TestOpenWithMap(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFormatNormalizedLiterals(t *testing.T) {
+	src := `syntax = "proto2";
+option (name) = 'x';
+option (quote) = 'say\x41\101';
+option (mask) = 0x1F;
+option (mode) = 017;
+option optimize_for = SPEED;
+message Limits {
+  optional double max = 1 [default = 1e3];
+  optional int64 min = 2 [default = -0x10];
+  optional Mode mode = 3 [default = FAST];
+  optional double inf = 4 [default = inf];
+  optional string rule = 5 [(v) = { pattern: "a\tb" size: 0x2 }];
+}
+`
+	expected := `syntax = "proto2";
+
+option (name) = "x";
+option (quote) = "sayAA";
+option (mask) = 31;
+option (mode) = 15;
+option optimize_for = SPEED;
+
+message Limits {
+  optional double max  = 1 [default = 1000];
+  optional int64  min  = 2 [default = -16 ];
+  optional Mode   mode = 3 [default = FAST];
+  optional double inf  = 4 [default = inf ];
+  optional string rule = 5 [(v)     = {
+    pattern: "a\tb"
+    size: 2
+  }];
+}
+`
+	def, err := newParserOn(src).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultFormatterOptions()
+	opts.NormalizeLiterals = true
+	opts.DecimalNumbers = true
+	b := new(bytes.Buffer)
+	NewFormatterWithOptions(b, opts).Format(def)
+	if got, want := b.String(), expected; got != want {
+		fmt.Println(diff(got, want))
+		fmt.Println(got)
+		t.Fail()
+	}
+	if err := Verify("limits.proto", []byte(src), opts); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuotedLiteral(t *testing.T) {
+	for _, each := range []struct {
+		source, want string
+	}{
+		{`a'b`, `"a'b"`},
+		{`\'\"\\`, `"'\"\\"`},
+		{`\n\r\t\a`, `"\n\r\t\x07"`},
+		{`\xffé`, `"\xffé"`},
+		{`\0\377`, `"\x00\xff"`},
+		{`\q`, ``},
+	} {
+		value, ok := unescaped(each.source)
+		if !ok {
+			if each.want != "" {
+				t.Errorf("%q: cannot unescape", each.source)
+			}
+			continue
+		}
+		if got := quoted(value); got != each.want {
+			t.Errorf("%q: got %s want %s", each.source, got, each.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package protofmt
+
+import (
+	"math"
+	"sort"
+
+	"github.com/emicklei/proto"
+)
+
+// orderKey is used to sort elements, first by category then by number.
+type orderKey struct {
+	category int
+	number   int
+}
+
+// trailingKey is the key of comments that are not followed by any element.
+var trailingKey = orderKey{category: math.MaxInt32}
+
+// reordered returns the elements stable sorted by their key.
+// Comments that are not attached to an element are moved together with the element that follows them.
+func reordered(elements []proto.Visitee, keyOf func(proto.Visitee) orderKey) []proto.Visitee {
+	type unit struct {
+		key      orderKey
+		elements []proto.Visitee
+	}
+	units := []unit{}
+	pending := []proto.Visitee{}
+	for _, each := range elements {
+		pending = append(pending, each)
+		if _, ok := each.(*proto.Comment); ok {
+			continue
+		}
+		units = append(units, unit{key: keyOf(each), elements: pending})
+		pending = []proto.Visitee{}
+	}
+	if len(pending) > 0 {
+		units = append(units, unit{key: trailingKey, elements: pending})
+	}
+	sort.SliceStable(units, func(i, j int) bool {
+		if units[i].key.category != units[j].key.category {
+			return units[i].key.category < units[j].key.category
+		}
+		return units[i].key.number < units[j].key.number
+	})
+	list := make([]proto.Visitee, 0, len(elements))
+	for _, each := range units {
+		list = append(list, each.elements...)
+	}
+	return list
+}
+
+// canonicalOrder returns the top-level elements in the order:
+// edition or syntax, package, imports, options, services, messages, enums and extends.
+func canonicalOrder(elements []proto.Visitee) []proto.Visitee {
+	return reordered(elements, func(v proto.Visitee) orderKey {
+		switch e := v.(type) {
+		case *proto.Edition, *proto.Syntax:
+			return orderKey{category: 0}
+		case *proto.Package:
+			return orderKey{category: 1}
+		case *proto.Import:
+			return orderKey{category: 2}
+		case *proto.Option:
+			return orderKey{category: 3}
+		case *proto.Service:
+			return orderKey{category: 4}
+		case *proto.Message:
+			if e.IsExtend {
+				return orderKey{category: 7}
+			}
+			return orderKey{category: 5}
+		case *proto.Enum:
+			return orderKey{category: 6}
+		}
+		return orderKey{category: 8}
+	})
+}
+
+// messageOrder returns the elements of a message (or oneof) in the order:
+// options, nested types, fields by number, reserved and extensions.
+func messageOrder(elements []proto.Visitee) []proto.Visitee {
+	return reordered(elements, func(v proto.Visitee) orderKey {
+		switch e := v.(type) {
+		case *proto.Option:
+			return orderKey{category: 0}
+		case *proto.Message, *proto.Enum:
+			return orderKey{category: 1}
+		case *proto.NormalField:
+			return orderKey{category: 2, number: e.Sequence}
+		case *proto.MapField:
+			return orderKey{category: 2, number: e.Sequence}
+		case *proto.OneOfField:
+			return orderKey{category: 2, number: e.Sequence}
+		case *proto.Group:
+			return orderKey{category: 2, number: e.Sequence}
+		case *proto.Oneof:
+			return orderKey{category: 2, number: lowestFieldNumber(e)}
+		case *proto.Reserved:
+			return orderKey{category: 3}
+		case *proto.Extensions:
+			return orderKey{category: 4}
+		}
+		return orderKey{category: 5}
+	})
+}
+
+// lowestFieldNumber returns the smallest number of the fields in a oneof.
+func lowestFieldNumber(o *proto.Oneof) int {
+	lowest := math.MaxInt32
+	for _, each := range o.Elements {
+		number := lowest
+		switch e := each.(type) {
+		case *proto.OneOfField:
+			number = e.Sequence
+		case *proto.Group:
+			number = e.Sequence
+		}
+		if number < lowest {
+			lowest = number
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,157 @@
+package protofmt
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// TextEdit replaces the bytes of a source from offset Start up to End by Text.
+type TextEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// FormatRange formats only the declarations that overlap the lines from startLine up to and including endLine (1-based).
+// If the lines are inside the body of a message, enum, service or oneof then only its overlapping elements are formatted,
+// together with the fields, values or rpc-s they are aligned with. Options that reorder elements are not applied.
+// It returns the edits to apply to the source; all other bytes of the source remain unchanged.
+func FormatRange(filename string, source []byte, startLine, endLine int, options FormatterOptions) ([]TextEdit, error) {
+	def, err := parse(filename, source)
+	if err != nil {
+		return nil, err
+	}
+	options.SortImports = false
+	options.CanonicalOrder = false
+	options.SortMessageElements = false
+	r := rangeFormatter{source: source, options: options}
+	if options.PreserveBlankLines {
+		r.blankBefore = blankLinesBefore(source, def.Elements)
+	}
+	first, last, text, ok := r.format(def.Elements, 0, startLine, endLine)
+	if !ok {
+		return nil, nil
+	}
+	edit := TextEdit{Start: offsetOfLine(source, first), End: offsetOfLine(source, last+1), Text: text}
+	if string(source[edit.Start:edit.End]) == edit.Text {
+		return nil, nil
+	}
+	return []TextEdit{edit}, nil
+}
+
+// ApplyEdits returns a copy of the source with the edits applied. Edits must not overlap.
+func ApplyEdits(source []byte, edits []TextEdit) []byte {
+	sorted := append([]TextEdit{}, edits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	buf := new(bytes.Buffer)
+	offset := 0
+	for _, each := range sorted {
+		buf.Write(source[offset:each.Start])
+		buf.WriteString(each.Text)
+		offset = each.End
+	}
+	buf.Write(source[offset:])
+	return buf.Bytes()
+}
+
+// rangeFormatter finds and formats the elements that overlap a range of lines.
+type rangeFormatter struct {
+	source      []byte
+	options     FormatterOptions
+	blankBefore map[proto.Visitee]bool
+}
+
+// format returns the first and last line of the overlapping elements of a list and their formatted source.
+func (r rangeFormatter) format(elements []proto.Visitee, depth, from, to int) (first, last int, text string, ok bool) {
+	lo, hi := -1, -1
+	for i, each := range elements {
+		if firstLine(each) <= to && lastLine(r.source, each) >= from {
+			if lo == -1 {
+				lo = i
+			}
+			hi = i
+		}
+	}
+	if lo == -1 {
+		return 0, 0, "", false
+	}
+	// include elements that share a line or are aligned with the overlapping ones
+	for lo > 0 && (lastLine(r.source, elements[lo-1]) >= firstLine(elements[lo]) || (depth > 0 && r.alignedWith(elements[lo-1], elements[lo]))) {
+		lo--
+	}
+	for hi < len(elements)-1 && (lastLine(r.source, elements[hi]) >= firstLine(elements[hi+1]) || (depth > 0 && r.alignedWith(elements[hi], elements[hi+1]))) {
+		hi++
+	}
+	first, last = firstLine(elements[lo]), lastLine(r.source, elements[hi])
+	// if the lines are inside a body then only format its elements
+	if lo == hi {
+		header := positionLine(elements[lo])
+		if body, ok := bodyElements(elements[lo]); ok && from > header && to < last {
+			innerFirst, innerLast, innerText, innerOk := r.format(body, depth+1, from, to)
+			if !innerOk || (innerFirst > header && innerLast < last) {
+				return innerFirst, innerLast, innerText, innerOk
+			}
+		}
+	}
+	return first, last, r.formatted(elements[lo:hi+1], depth), true
+}
+
+// formatted returns the source of a list of elements formatted at an indentation level.
+func (r rangeFormatter) formatted(elements []proto.Visitee, depth int) string {
+	buf := new(bytes.Buffer)
+	f := NewFormatterWithOptions(buf, r.options)
+	f.blankBefore = r.blankBefore
+	if depth == 0 {
+		f.Format(&proto.Proto{Elements: elements})
+	} else {
+		f.indentLevel = depth
+		f.printAsGroups(elements)
+	}
+	// an empty line before the first element is outside the range
+	return strings.TrimLeft(buf.String(), "\n")
+}
+
+// alignedWith returns true if the next element is printed in the same group of aligned columns.
+func (r rangeFormatter) alignedWith(previous, next proto.Visitee) bool {
+	if _, ok := typeAssertColumnsPrintable(previous, r.options, 0); !ok {
+		return false
+	}
+	if _, ok := typeAssertColumnsPrintable(next, r.options, 0); !ok {
+		return false
+	}
+	if hasDoc, ok := next.(proto.Documented); ok && hasDoc.Doc() != nil {
+		return false
+	}
+	return nameOfVisitee(previous) == nameOfVisitee(next) && !r.blankBefore[next]
+}
+
+// bodyElements returns the elements of an element that has a body with declarations.
+func bodyElements(v proto.Visitee) ([]proto.Visitee, bool) {
+	switch e := v.(type) {
+	case *proto.Message:
+		return e.Elements, true
+	case *proto.Enum:
+		return e.Elements, true
+	case *proto.Service:
+		return e.Elements, true
+	case *proto.Oneof:
+		return e.Elements, true
+	}
+	return nil, false
+}
+
+// offsetOfLine returns the offset of the first byte of a line (1-based), or the length of the source if there is no such line.
+func offsetOfLine(source []byte, line int) int {
+	offset := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(source[offset:], '\n')
+		if i == -1 {
+			return len(source)
+		}
+		offset += i + 1
+	}
+	return offset
+}
@@ -0,0 +1,89 @@
+package protofmt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFormatRange(t *testing.T) {
+	src := `syntax   =   "proto3";
+message A {
+int32 id = 1;
+  string    name = 2;
+
+    // other
+    bool other = 3;
+  message B { int64  b =  1; }
+}
+message   C {}
+`
+	for i, each := range []struct {
+		from, to int
+		want     string
+	}{
+		// a field and the one it is aligned with
+		{3, 3, `syntax   =   "proto3";
+message A {
+  int32  id   = 1;
+  string name = 2;
+
+    // other
+    bool other = 3;
+  message B { int64  b =  1; }
+}
+message   C {}
+`},
+		// a documented field
+		{7, 7, `syntax   =   "proto3";
+message A {
+int32 id = 1;
+  string    name = 2;
+
+  // other
+  bool other = 3;
+  message B { int64  b =  1; }
+}
+message   C {}
+`},
+		// a nested message on one line
+		{8, 8, `syntax   =   "proto3";
+message A {
+int32 id = 1;
+  string    name = 2;
+
+    // other
+    bool other = 3;
+  message B {
+    int64 b = 1;
+  }
+}
+message   C {}
+`},
+		// top-level declarations
+		{9, 10, `syntax   =   "proto3";
+message A {
+  int32  id   = 1;
+  string name = 2;
+
+  // other
+  bool other = 3;
+  message B {
+    int64 b = 1;
+  }
+}
+
+message C {}
+`},
+		// only an empty line
+		{5, 5, src},
+	} {
+		edits, err := FormatRange("a.proto", []byte(src), each.from, each.to, DefaultFormatterOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(ApplyEdits([]byte(src), edits)), each.want; got != want {
+			fmt.Println(diff(got, want))
+			t.Errorf("%d: lines %d-%d\n%s", i, each.from, each.to, got)
+		}
+	}
+}
@@ -30,9 +30,6 @@ type reflector struct {
 	name string
 }
 
-// sole instance of reflector
-var namer = new(reflector)
-
 func (r *reflector) VisitMessage(m *proto.Message)         { r.name = "Message" }
 func (r *reflector) VisitService(v *proto.Service)         { r.name = "Service" }
 func (r *reflector) VisitSyntax(s *proto.Syntax)           { r.name = "Syntax" }
@@ -52,7 +49,9 @@ func (r *reflector) VisitGroup(g *proto.Group)             { r.name = "Group" }
 func (r *reflector) VisitExtensions(e *proto.Extensions)   { r.name = "Extensions" }
 
 // nameOfVisitee returns the short type name of a Visitee.
+// A new reflector is used for each call because formatters may run concurrently.
 func nameOfVisitee(e proto.Visitee) string {
+	namer := new(reflector)
 	e.Accept(namer)
 	return namer.name
 }
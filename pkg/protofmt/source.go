@@ -0,0 +1,88 @@
+package protofmt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// SyntaxError describes why a source cannot be parsed.
+type SyntaxError struct {
+	Filename string
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Column, e.Message)
+}
+
+// Source formats the source of a proto definition using the options, like go/format does for Go source.
+// If the source cannot be parsed then the error is a *SyntaxError.
+func Source(src []byte, options FormatterOptions) ([]byte, error) {
+	def, err := parse("<input>", src)
+	if err != nil {
+		return nil, err
+	}
+	return formatWith(def, src, options), nil
+}
+
+// Node formats a definition or a single element, such as a message, enum or service, as a top-level declaration.
+// Without the source, empty lines are not preserved. It returns the first error of writing, if any.
+func Node(w io.Writer, v proto.Visitee, options FormatterOptions) error {
+	ew := &errorWriter{w: w}
+	f := NewFormatterWithOptions(ew, options)
+	if p, ok := v.(*proto.Proto); ok {
+		f.Format(p)
+		return ew.err
+	}
+	flush := f.buffered()
+	v.Accept(f)
+	flush()
+	return ew.err
+}
+
+// errorWriter keeps the first error of writing and then discards all writes.
+type errorWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errorWriter) Write(data []byte) (int, error) {
+	if e.err != nil {
+		return len(data), nil
+	}
+	_, e.err = e.w.Write(data)
+	return len(data), nil
+}
+
+// parse parses a source; its error is a *SyntaxError.
+func parse(filename string, source []byte) (*proto.Proto, error) {
+	parser := proto.NewParser(bytes.NewReader(source))
+	parser.Filename(filename)
+	def, err := parser.Parse()
+	if err != nil {
+		return nil, syntaxError(filename, err)
+	}
+	return def, nil
+}
+
+// syntaxErrorPattern matches the position that prefixes the message of a parse or scanner error.
+var syntaxErrorPattern = regexp.MustCompile(`(?s)^(?:go scanner error at )?(?:(.*?):)?(\d+):(\d+)(?:: | = )(.*)$`)
+
+// syntaxError returns the error of the parser as a *SyntaxError. Without a position, it is at the first line.
+func syntaxError(filename string, err error) *SyntaxError {
+	m := syntaxErrorPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return &SyntaxError{Filename: filename, Line: 1, Column: 1, Message: err.Error()}
+	}
+	line, _ := strconv.Atoi(m[2])
+	column, _ := strconv.Atoi(m[3])
+	return &SyntaxError{Filename: filename, Line: line, Column: column, Message: strings.TrimSpace(m[4])}
+}
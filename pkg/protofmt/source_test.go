@@ -0,0 +1,53 @@
+package protofmt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	got, err := Source([]byte("syntax=\"proto3\";message A{int32 id=1;}"), DefaultFormatterOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "syntax = \"proto3\";\n\nmessage A {\n  int32 id = 1;\n}\n"; string(got) != want {
+		fmt.Println(diff(string(got), want))
+		t.Fail()
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	for _, each := range []struct {
+		src          string
+		line, column int
+		message      string
+	}{
+		{"message A {\n  int32 id = ;\n}\n", 2, 14, `found ";" but expected [field sequence number]`},
+		{"message A {\n  option (x) = \"open;\n}\n", 2, 16, "literal not terminated"},
+	} {
+		_, err := Source([]byte(each.src), DefaultFormatterOptions())
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Fatalf("got %v want *SyntaxError", err)
+		}
+		if syntaxErr.Line != each.line || syntaxErr.Column != each.column || syntaxErr.Message != each.message {
+			t.Errorf("got %d:%d %q want %d:%d %q", syntaxErr.Line, syntaxErr.Column, syntaxErr.Message, each.line, each.column, each.message)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	def, err := newParserOn(`message A { int32 id = 1; string name = 2; }`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := Node(b, def.Elements[0], DefaultFormatterOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "message A {\n  int32  id   = 1;\n  string name = 2;\n}\n"; got != want {
+		fmt.Println(diff(got, want))
+		t.Fail()
+	}
+}
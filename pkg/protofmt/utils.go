@@ -35,37 +35,92 @@ import (
 func (f *Formatter) printDoc(v proto.Visitee) {
 	if hasDoc, ok := v.(proto.Documented); ok {
 		if doc := hasDoc.Doc(); doc != nil {
-			f.printComment(doc)
+			f.printComment(doc, true)
 		}
 	}
 }
 
-// printComment formats a Comment.
-func (f *Formatter) printComment(c *proto.Comment) {
-	if c.Cstyle {
+// printComment formats a Comment, which is documentation if isDoc.
+func (f *Formatter) printComment(c *proto.Comment, isDoc bool) {
+	for _, each := range f.formattedComment(c, isDoc) {
 		f.indent(0)
-		fmt.Fprintln(f.w, "/*")
+		fmt.Fprintf(f.w, "%s\n", each)
 	}
-	for i, each := range c.Lines {
+}
+
+// commentLines returns the lines of a Comment, including the comment markers, written in the requested style.
+func commentLines(c *proto.Comment, style CommentStyle) (lines []string) {
+	asBlock := writtenAsBlock(c, style)
+	if asBlock {
+		lines = append(lines, "/*")
+	}
+	source := c.Lines
+	if c.Cstyle {
+		source = dedented(c.Lines)
+	}
+	for i, each := range source {
 		each = strings.TrimRight(each, " ")
-		f.indent(0)
 		if c.Cstyle {
 			// only skip first and last empty lines
 			skip := (i == 0 && len(each) == 0) ||
-				(i == len(c.Lines)-1 && len(each) == 0)
-			if !skip {
-				fmt.Fprintf(f.w, "%s\n", each)
+				(i == len(source)-1 && len(each) == 0)
+			if skip {
+				continue
 			}
-		} else {
-			if c.ExtraSlash {
-				fmt.Fprint(f.w, "/")
+		}
+		switch {
+		case asBlock && c.Cstyle && strings.HasPrefix(each, "*"):
+			// align the star with the one that starts the comment
+			lines = append(lines, " "+each)
+		case asBlock && c.Cstyle:
+			lines = append(lines, each)
+		case asBlock:
+			lines = append(lines, " *"+each)
+		case c.Cstyle:
+			// remove the leading star of a block comment line
+			if trimmed := strings.TrimLeft(each, " \t"); strings.HasPrefix(trimmed, "*") {
+				each = trimmed[1:]
+			} else if len(each) > 0 {
+				each = " " + each
 			}
-			fmt.Fprintf(f.w, "//%s\n", each)
+			lines = append(lines, "//"+each)
+		case c.ExtraSlash:
+			lines = append(lines, "///"+each)
+		default:
+			lines = append(lines, "//"+each)
 		}
 	}
-	if c.Cstyle {
-		fmt.Fprintf(f.w, " */\n")
+	if asBlock {
+		lines = append(lines, " */")
 	}
+	return
+}
+
+// dedented returns the lines of a block comment without the indentation they have in common.
+// The first line, which follows the opening marker, is trimmed.
+func dedented(lines []string) []string {
+	common := -1
+	for _, each := range lines[1:] {
+		if trimmed := strings.TrimLeft(each, " \t"); len(trimmed) > 0 {
+			if n := len(each) - len(trimmed); common == -1 || n < common {
+				common = n
+			}
+		}
+	}
+	list := make([]string, len(lines))
+	for i, each := range lines {
+		switch {
+		case i == 0:
+			list[i] = strings.TrimSpace(each)
+		case common > 0 && len(each) > common:
+			list[i] = strings.TrimRight(each[common:], " \t")
+		case common > 0:
+			list[i] = strings.TrimSpace(each)
+		default:
+			list[i] = strings.TrimRight(each, " \t")
+		}
+	}
+	return list
 }
 
 // begin writes a newline if the last statement kind is different. always indents.
@@ -97,26 +152,29 @@ func (f *Formatter) printListOfColumns(list []columnsPrintable) {
 		return
 	}
 	// collect all column values
-	values := [][]aligned{}
-	widths := map[int]int{}
-	for _, each := range list {
+	values := make([][]aligned, len(list))
+	widths := []int{}
+	// rows that are too wide do not take part in the alignment
+	overlong := make([]bool, len(list))
+	for r, each := range list {
 		cols := each.columns()
-		values = append(values, cols)
+		values[r] = cols
+		if exceedsMaxLineWidth(f.options, f.indentLevel, cols) || isWrapped(each) {
+			overlong[r] = true
+			continue
+		}
 		// update max widths per column
+		for len(widths) < len(cols) {
+			widths = append(widths, 0)
+		}
 		for i, other := range cols {
-			pw := other.preferredWidth()
-			w, ok := widths[i]
-			if ok {
-				if pw > w {
-					widths[i] = pw
-				}
-			} else {
+			if pw := other.preferredWidth(); pw > widths[i] {
 				widths[i] = pw
 			}
 		}
 	}
 	// now print all values
-	for _, each := range values {
+	for r, each := range values {
 		hasValue := false
 		for a := 0; a < len(each); a++ {
 			if each[a].source != "" {
@@ -126,13 +184,15 @@ func (f *Formatter) printListOfColumns(list []columnsPrintable) {
 		}
 		if hasValue {
 			f.indent(0)
-			for c := 0; c < len(widths); c++ {
-				// only print if there is a value
-				if c < len(each) {
-					pw := widths[c]
-					// using space padding to match the max width
-					io.WriteString(f.w, each[c].formatted(f.indentSeparator, f.indentLevel, pw))
+			for c := 0; c < len(each); c++ {
+				pw := 0
+				if overlong[r] {
+					pw = each[c].preferredWidth()
+				} else if c < len(widths) {
+					pw = widths[c]
 				}
+				// using space padding to match the max width
+				io.WriteString(f.w, each[c].formatted(f.options, f.indentLevel, pw))
 			}
 		}
 		f.nl()
@@ -144,6 +204,20 @@ func (f *Formatter) nl() {
 	io.WriteString(f.w, "\n")
 }
 
+// preservedBlankLine writes a newline if blank lines are preserved and the source has one or more before the element.
+func (f *Formatter) preservedBlankLine(v proto.Visitee) {
+	if f.blankBefore[v] {
+		f.nl()
+	}
+}
+
+// blankLines writes the configured number of newlines between top-level declarations.
+func (f *Formatter) blankLines() {
+	for i := 0; i < f.options.BlankLinesBetweenDeclarations; i++ {
+		f.nl()
+	}
+}
+
 // level changes the current indentLevel but does not print indentation.
 func (f *Formatter) level(diff int) {
 	f.lastLevel = f.indentLevel
@@ -154,9 +228,15 @@ func (f *Formatter) level(diff int) {
 func (f *Formatter) printAsGroups(list []proto.Visitee) {
 	group := []columnsPrintable{}
 	lastGroupName := ""
-	for _, each := range list {
+	for i, each := range list {
 		groupName := nameOfVisitee(each)
-		printable, isColumnsPrintable := typeAssertColumnsPrintable(each)
+		printable, isColumnsPrintable := typeAssertColumnsPrintable(each, f.options, f.indentLevel)
+		// an empty line in the source ends the group
+		if i > 0 && f.blankBefore[each] {
+			f.printListOfColumns(group)
+			group = []columnsPrintable{}
+			f.nl()
+		}
 		if isColumnsPrintable {
 			if lastGroupName != groupName {
 				lastGroupName = groupName
@@ -173,10 +253,10 @@ func (f *Formatter) printAsGroups(list []proto.Visitee) {
 					f.printListOfColumns(group)
 					// begin new group
 					group = []columnsPrintable{}
-					if len(doc.Lines) > 0 { // if comment then add newline before it
+					if len(doc.Lines) > 0 && !f.options.PreserveBlankLines { // if comment then add newline before it
 						group = append(group, inlineComment{line: "", extraSlash: false})
 					}
-					group = append(group, columnsPrintables(doc)...)
+					group = append(group, columnsPrintables(f.formattedComment(doc, true))...)
 				}
 			}
 			group = append(group, printable)
@@ -190,12 +270,28 @@ func (f *Formatter) printAsGroups(list []proto.Visitee) {
 				group = []columnsPrintable{}
 			}
 			each.Accept(f)
+			// keep a comment that is followed by an element separated from it, otherwise it becomes its documentation
+			if _, ok := each.(*proto.Comment); ok && i < len(list)-1 && !f.options.PreserveBlankLines && !f.startsWithEmptyLine(list[i+1]) {
+				f.nl()
+			}
 		}
 	}
 	// print last group
 	f.printListOfColumns(group)
 }
 
+// startsWithEmptyLine returns true if printAsGroups writes an empty line before the documentation of the element.
+func (f *Formatter) startsWithEmptyLine(v proto.Visitee) bool {
+	if _, ok := typeAssertColumnsPrintable(v, f.options, f.indentLevel); !ok {
+		return false
+	}
+	if hasDoc, ok := v.(proto.Documented); ok {
+		doc := hasDoc.Doc()
+		return doc != nil && len(doc.Lines) > 0
+	}
+	return false
+}
+
 // endWithComment writes a statement end (;) followed by inline comment if present.
 func (f *Formatter) endWithComment(commentOrNil *proto.Comment) {
 	io.WriteString(f.w, ";")
@@ -0,0 +1,306 @@
+package protofmt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/proto"
+)
+
+// VerifyError describes the first divergence found by Verify.
+type VerifyError struct {
+	Filename string
+	// Line is the line in the source, or in the formatted source if the divergence is found there.
+	Line    int
+	Message string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Filename, e.Line, e.Message)
+}
+
+// Verify formats the source twice and checks that the second result is identical to the first
+// and that the formatted source has the same elements, including every comment, as the original.
+// It returns a *VerifyError describing the first divergence, or the error of parsing the source.
+func Verify(filename string, source []byte, options FormatterOptions) error {
+	original, err := parse(filename, source)
+	if err != nil {
+		return err
+	}
+	once := formatWith(original, source, options)
+	formatted, err := parse(filename, once)
+	if err != nil {
+		return &VerifyError{Filename: filename, Line: 1, Message: "formatted source cannot be parsed: " + err.Error()}
+	}
+	twice := formatWith(formatted, once, options)
+	if line, ok := firstDifferentLine(once, twice); ok {
+		return &VerifyError{Filename: filename, Line: line,
+			Message: fmt.Sprintf("formatting twice changes line %q into %q (formatted source line)", lineOf(once, line), lineOf(twice, line))}
+	}
+	// reordering options may change the order of elements but not the elements themselves
+	ordered := !options.SortImports && !options.CanonicalOrder && !options.SortMessageElements
+	return firstDivergence(filename, original, formatted, ordered)
+}
+
+// formatWith formats a definition parsed from a source.
+func formatWith(p *proto.Proto, source []byte, options FormatterOptions) []byte {
+	buf := new(bytes.Buffer)
+	f := NewFormatterWithOptions(buf, options)
+	f.SetSource(source)
+	f.Format(p)
+	return buf.Bytes()
+}
+
+// lineOf returns the text of a line, empty if there is no such line.
+func lineOf(source []byte, line int) string {
+	lines := strings.Split(string(source), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return lines[line-1]
+}
+
+// firstDifferentLine returns the number of the first line that differs.
+func firstDifferentLine(a, b []byte) (int, bool) {
+	if bytes.Equal(a, b) {
+		return 0, false
+	}
+	linesA, linesB := strings.Split(string(a), "\n"), strings.Split(string(b), "\n")
+	for i := 0; i < len(linesA) && i < len(linesB); i++ {
+		if linesA[i] != linesB[i] {
+			return i + 1, true
+		}
+	}
+	if len(linesA) < len(linesB) {
+		return len(linesA), true
+	}
+	return len(linesB), true
+}
+
+// firstDivergence compares the element trees of two definitions.
+// If ordered is false then the elements are compared regardless of their order.
+func firstDivergence(filename string, before, after *proto.Proto, ordered bool) error {
+	want, got := treeEntries(before), treeEntries(after)
+	if !ordered {
+		want, got = sortedEntries(want), sortedEntries(got)
+	}
+	for i := 0; i < len(want) || i < len(got); i++ {
+		if i < len(want) && i < len(got) && want[i].text == got[i].text {
+			continue
+		}
+		if i < len(want) && !containsEntry(got[i:], want[i].text) {
+			return &VerifyError{Filename: filename, Line: want[i].line, Message: "missing after formatting: " + want[i].text}
+		}
+		if i < len(got) && !containsEntry(want[i:], got[i].text) {
+			return &VerifyError{Filename: filename, Line: got[i].line, Message: "unexpected after formatting (formatted source line): " + got[i].text}
+		}
+		return &VerifyError{Filename: filename, Line: want[i].line, Message: "moved by formatting: " + want[i].text}
+	}
+	return nil
+}
+
+func containsEntry(list []treeEntry, text string) bool {
+	for _, each := range list {
+		if each.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedEntries returns the entries sorted by text with duplicates removed.
+func sortedEntries(list []treeEntry) []treeEntry {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].text < list[j].text })
+	unique := []treeEntry{}
+	for i, each := range list {
+		if i == 0 || list[i-1].text != each.text {
+			unique = append(unique, each)
+		}
+	}
+	return unique
+}
+
+// treeEntry describes an element or comment, prefixed by the path of its parents.
+type treeEntry struct {
+	line int
+	text string
+}
+
+func treeEntries(p *proto.Proto) []treeEntry {
+	w := &treeWalker{}
+	w.walk(p.Elements)
+	return w.entries
+}
+
+// treeWalker collects the entries of all (nested) elements and their comments.
+type treeWalker struct {
+	path    []string
+	entries []treeEntry
+}
+
+func (w *treeWalker) walk(elements []proto.Visitee) {
+	for _, each := range elements {
+		if e, ok := each.(*proto.Edition); ok {
+			// an Edition does not dispatch to a Visitor (yet)
+			w.add(e.Position.Line, e.Comment, "edition "+e.Value, e.InlineComment)
+			continue
+		}
+		each.Accept(w)
+	}
+}
+
+// add adds the entries for an element and its comments.
+func (w *treeWalker) add(line int, doc *proto.Comment, text string, inline *proto.Comment) {
+	path := strings.Join(w.path, ".")
+	if len(path) > 0 {
+		text = path + ": " + text
+	}
+	if doc != nil {
+		w.entries = append(w.entries, treeEntry{line: doc.Position.Line, text: text + " with comment " + commentText(doc)})
+	}
+	w.entries = append(w.entries, treeEntry{line: line, text: text})
+	if inline != nil {
+		w.entries = append(w.entries, treeEntry{line: line, text: text + " with inline comment " + commentText(inline)})
+	}
+}
+
+// nested walks the elements of a container, adding its name to the path.
+func (w *treeWalker) nested(name string, elements []proto.Visitee) {
+	w.path = append(w.path, name)
+	w.walk(elements)
+	w.path = w.path[:len(w.path)-1]
+}
+
+// commentText returns the words of a comment without markers; reflowing may change its spacing and line breaks.
+func commentText(c *proto.Comment) string {
+	words := []string{}
+	for _, each := range c.Lines {
+		each = strings.TrimSpace(each)
+		if c.Cstyle {
+			each = strings.TrimPrefix(each, "*")
+		}
+		words = append(words, strings.Fields(each)...)
+	}
+	return fmt.Sprintf("%q", strings.Join(words, " "))
+}
+
+// optionsText returns the names and values of options.
+func optionsText(options []*proto.Option) string {
+	list := []string{}
+	for _, each := range options {
+		list = append(list, optionText(each))
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// literalsCompared are the options to write literals for comparison: normalized, on one line.
+var literalsCompared = FormatterOptions{NormalizeLiterals: true, DecimalNumbers: true}
+
+func optionText(o *proto.Option) string {
+	return fmt.Sprintf("%s = %s", o.Name, strings.Join(strings.Fields(literalSource(&o.Constant, literalsCompared)), " "))
+}
+
+func (w *treeWalker) VisitMessage(m *proto.Message) {
+	kind := "message"
+	if m.IsExtend {
+		kind = "extend"
+	}
+	w.add(m.Position.Line, m.Comment, kind+" "+m.Name, nil)
+	w.nested(m.Name, m.Elements)
+}
+
+func (w *treeWalker) VisitService(s *proto.Service) {
+	w.add(s.Position.Line, s.Comment, "service "+s.Name, nil)
+	w.nested(s.Name, s.Elements)
+}
+
+func (w *treeWalker) VisitSyntax(s *proto.Syntax) {
+	w.add(s.Position.Line, s.Comment, "syntax "+s.Value, s.InlineComment)
+}
+
+func (w *treeWalker) VisitPackage(p *proto.Package) {
+	w.add(p.Position.Line, p.Comment, "package "+p.Name, p.InlineComment)
+}
+
+func (w *treeWalker) VisitOption(o *proto.Option) {
+	w.add(o.Position.Line, o.Comment, "option "+optionText(o), o.InlineComment)
+}
+
+func (w *treeWalker) VisitImport(i *proto.Import) {
+	w.add(i.Position.Line, i.Comment, strings.TrimSpace("import "+i.Kind)+" "+i.Filename, i.InlineComment)
+}
+
+func (w *treeWalker) VisitNormalField(f *proto.NormalField) {
+	label := ""
+	switch {
+	case f.Repeated:
+		label = "repeated "
+	case f.Required:
+		label = "required "
+	case f.Optional:
+		label = "optional "
+	}
+	w.add(f.Position.Line, f.Comment, fmt.Sprintf("field %s%s %s = %d %s", label, f.Type, f.Name, f.Sequence, optionsText(f.Options)), f.InlineComment)
+}
+
+func (w *treeWalker) VisitEnumField(f *proto.EnumField) {
+	options := []*proto.Option{}
+	for _, each := range f.Elements {
+		if o, ok := each.(*proto.Option); ok {
+			options = append(options, o)
+		}
+	}
+	w.add(f.Position.Line, f.Comment, fmt.Sprintf("value %s = %d %s", f.Name, f.Integer, optionsText(options)), f.InlineComment)
+}
+
+func (w *treeWalker) VisitEnum(e *proto.Enum) {
+	w.add(e.Position.Line, e.Comment, "enum "+e.Name, nil)
+	w.nested(e.Name, e.Elements)
+}
+
+func (w *treeWalker) VisitComment(c *proto.Comment) {
+	w.add(c.Position.Line, nil, "comment "+commentText(c), nil)
+}
+
+func (w *treeWalker) VisitOneof(o *proto.Oneof) {
+	w.add(o.Position.Line, o.Comment, "oneof "+o.Name, nil)
+	w.nested(o.Name, o.Elements)
+}
+
+func (w *treeWalker) VisitOneofField(o *proto.OneOfField) {
+	w.add(o.Position.Line, o.Comment, fmt.Sprintf("field %s %s = %d %s", o.Type, o.Name, o.Sequence, optionsText(o.Options)), o.InlineComment)
+}
+
+func (w *treeWalker) VisitReserved(r *proto.Reserved) {
+	w.add(r.Position.Line, r.Comment, fmt.Sprintf("reserved %v %q", r.Ranges, r.FieldNames), r.InlineComment)
+}
+
+func (w *treeWalker) VisitRPC(r *proto.RPC) {
+	w.add(r.Position.Line, r.Comment, fmt.Sprintf("rpc %s(%v %s) returns (%v %s)",
+		r.Name, r.StreamsRequest, r.RequestType, r.StreamsReturns, r.ReturnsType), r.InlineComment)
+	w.nested(r.Name, r.Elements)
+}
+
+func (w *treeWalker) VisitMapField(f *proto.MapField) {
+	w.add(f.Position.Line, f.Comment, fmt.Sprintf("field map<%s,%s> %s = %d %s", f.KeyType, f.Type, f.Name, f.Sequence, optionsText(f.Options)), f.InlineComment)
+}
+
+func (w *treeWalker) VisitGroup(g *proto.Group) {
+	label := ""
+	switch {
+	case g.Repeated:
+		label = "repeated "
+	case g.Required:
+		label = "required "
+	case g.Optional:
+		label = "optional "
+	}
+	w.add(g.Position.Line, g.Comment, fmt.Sprintf("group %s%s = %d", label, g.Name, g.Sequence), nil)
+	w.nested(g.Name, g.Elements)
+}
+
+func (w *treeWalker) VisitExtensions(e *proto.Extensions) {
+	w.add(e.Position.Line, e.Comment, fmt.Sprintf("extensions %v %s", e.Ranges, optionsText(e.Options)), e.InlineComment)
+}
@@ -0,0 +1,82 @@
+package protofmt
+
+import (
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	src := `syntax = "proto3";
+import "b.proto";
+import "a.proto";
+message A {
+    /*
+        indented block
+    */
+  int32 id = 1; // the id
+
+  // no longer used
+  // string name = 2;
+
+  oneof choice {
+    string text = 3 [(x) = {a: 1 b: [1, 2]}];
+  }
+}
+`
+	all := DefaultFormatterOptions()
+	all.SortImports = true
+	all.CanonicalOrder = true
+	all.SortMessageElements = true
+	all.PreserveBlankLines = true
+	all.MaxLineWidth = 40
+	for _, each := range []FormatterOptions{DefaultFormatterOptions(), all} {
+		if err := Verify("a.proto", []byte(src), each); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestVerifyReportsMissingComment(t *testing.T) {
+	before, err := parse("a.proto", []byte(`message A {
+  int32 id = 1;
+  // the name
+  string name = 2;
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := parse("a.proto", []byte(`message A {
+  int32 id = 1;
+  string name = 2;
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = firstDivergence("a.proto", before, after, true)
+	verr, ok := err.(*VerifyError)
+	if !ok {
+		t.Fatalf("got %v want *VerifyError", err)
+	}
+	if got, want := verr.Line, 3; got != want {
+		t.Errorf("got %d want %d", got, want)
+	}
+	if got, want := verr.Error(), `a.proto:3: missing after formatting: A: field string name = 2 [] with comment "the name"`; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestFirstDifferentLine(t *testing.T) {
+	for _, each := range []struct {
+		a, b string
+		line int
+		ok   bool
+	}{
+		{"a\nb\n", "a\nb\n", 0, false},
+		{"a\nb\n", "a\nc\n", 2, true},
+		{"a\nb\n", "a\nb\nc\n", 3, true},
+	} {
+		line, ok := firstDifferentLine([]byte(each.a), []byte(each.b))
+		if line != each.line || ok != each.ok {
+			t.Errorf("%q,%q: got %d,%v want %d,%v", each.a, each.b, line, ok, each.line, each.ok)
+		}
+	}
+}